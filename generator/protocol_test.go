@@ -0,0 +1,31 @@
+package generator
+
+import (
+	"bytes"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// generatedTag is a class tag of a protocol wrapper, it's registered by tests of the objc package.
+const generatedTag = "../objc/testdata/protocol_tag.txt"
+
+func TestProtocolClassTag(t *testing.T) {
+	p := &ProtocolType{BaseNode: BaseNode{Name: "NSCopying"}}
+	buf := new(bytes.Buffer)
+	if !p.PrintGoWrapper(buf) {
+		t.Fatal("wrapper was not printed")
+	}
+	m := regexp.MustCompile("objc.Object `(objc:\"[^`]*\")`").FindStringSubmatch(buf.String())
+	if m == nil {
+		t.Fatalf("class tag not found:\n%s", buf)
+	}
+	exp, err := ioutil.ReadFile(generatedTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m[1]; got != strings.TrimSpace(string(exp)) {
+		t.Errorf("class tag changed: %s, update %s", got, generatedTag)
+	}
+}
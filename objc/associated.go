@@ -0,0 +1,116 @@
+package objc
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// AssociationPolicy specifies how an associated object is kept by the owner.
+type AssociationPolicy uintptr
+
+const (
+	// AssociationAssign keeps a weak reference to the associated object.
+	AssociationAssign AssociationPolicy = 0
+	// AssociationRetainNonatomic retains the associated object. The association is not made atomically.
+	AssociationRetainNonatomic AssociationPolicy = 1
+	// AssociationCopyNonatomic copies the associated object. The association is not made atomically.
+	AssociationCopyNonatomic AssociationPolicy = 3
+	// AssociationRetain retains the associated object. The association is made atomically.
+	AssociationRetain AssociationPolicy = 01401
+	// AssociationCopy copies the associated object. The association is made atomically.
+	AssociationCopy AssociationPolicy = 01403
+)
+
+func (p AssociationPolicy) String() string {
+	switch p {
+	case AssociationAssign:
+		return "assign"
+	case AssociationRetainNonatomic:
+		return "retain_nonatomic"
+	case AssociationCopyNonatomic:
+		return "copy_nonatomic"
+	case AssociationRetain:
+		return "retain"
+	case AssociationCopy:
+		return "copy"
+	}
+	return fmt.Sprintf("AssociationPolicy(%#o)", uintptr(p))
+}
+
+// AssociationKey is a unique key of an associated object.
+type AssociationKey struct {
+	key unsafe.Pointer
+}
+
+// NewAssociationKey allocates a new unique key for associated objects.
+// Keys are expected to live as long as the program, thus they are never freed.
+func NewAssociationKey() *AssociationKey {
+	return &AssociationKey{key: malloc(1)}
+}
+
+// SetAssociatedObject associates an object with the receiver for a given key.
+// Setting a nil object removes the association.
+//
+// It returns an error if the runtime does not support associated objects.
+//
+// See objc_setAssociatedObject.
+func (o Object) SetAssociatedObject(key *AssociationKey, val Object, policy AssociationPolicy) error {
+	if o.IsNil() {
+		return fmt.Errorf("objc: set associated object of nil object")
+	} else if key == nil {
+		return fmt.Errorf("objc: nil association key")
+	}
+	if !objc_setAssociatedObject(o.id, key.key, val.id, uintptr(policy)) {
+		return fmt.Errorf("objc: associated objects are not supported by the runtime")
+	}
+	return nil
+}
+
+// AssociatedObject returns an object associated with the receiver for a given key.
+//
+// See objc_getAssociatedObject.
+func (o Object) AssociatedObject(key *AssociationKey) Object {
+	if o.IsNil() || key == nil {
+		return Object{}
+	}
+	return Object{id: objc_getAssociatedObject(o.id, key.key)}
+}
+
+// RemoveAssociatedObjects removes all associations of the receiver, including Go values.
+//
+// See objc_removeAssociatedObjects.
+func (o Object) RemoveAssociatedObjects() {
+	if o.IsNil() {
+		return
+	}
+	objc_removeAssociatedObjects(o.id)
+}
+
+// SetAssociatedValue associates a Go value with the receiver for a given key.
+// The value is kept alive until the association is replaced or removed, or the receiver
+// is deallocated. Setting a nil value removes the association.
+//
+// Go values are stored in GoBox objects, see Box.
+func (o Object) SetAssociatedValue(key *AssociationKey, val interface{}) error {
+	if val == nil {
+		return o.SetAssociatedObject(key, Object{}, AssociationRetain)
+	}
+	if o.IsNil() {
+		return fmt.Errorf("objc: set associated value of nil object")
+	}
+	b, err := Box(val)
+	if err != nil {
+		return err
+	}
+	err = o.SetAssociatedObject(key, b, AssociationRetain)
+	// the association keeps its own reference
+	b.Release()
+	return err
+}
+
+// AssociatedValue returns a Go value associated with the receiver for a given key.
+// It returns nil if there is no value, or if the associated object is not a Go value.
+func (o Object) AssociatedValue(key *AssociationKey) interface{} {
+	v, _ := Unbox(o.AssociatedObject(key))
+	return v
+}
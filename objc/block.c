@@ -0,0 +1,55 @@
+//go:build !objcfake
+// +build !objcfake
+
+#include <stdint.h>
+#include <string.h>
+#include "block.h"
+#include "_cgo_export.h"
+
+// Copy and dispose helpers of blocks implemented in Go.
+// Each copy of a block holds its own handle of the Go function.
+
+void go_block_copy(void *dst, const void *src) {
+	((struct go_block*)dst)->handle = goBlockCopy(((const struct go_block*)src)->handle);
+}
+
+void go_block_dispose(const void *src) {
+	goBlockDispose(((const struct go_block*)src)->handle);
+}
+
+// Trampolines that are used as invoke functions of blocks implemented in Go.
+// See also go_imp_int in trampoline.c.
+
+static uint64_t go_block_invoke(struct go_block *b,
+	uintptr_t a0, uintptr_t a1, uintptr_t a2, uintptr_t a3,
+	double f0, double f1, double f2, double f3, double f4, double f5, double f6, double f7) {
+	uintptr_t a[4] = {a0, a1, a2, a3};
+	double f[8] = {f0, f1, f2, f3, f4, f5, f6, f7};
+	uint64_t out = 0;
+	goObjcBlock(b->handle, a, f, &out);
+	return out;
+}
+
+uintptr_t go_block_int(struct go_block *b,
+	uintptr_t a0, uintptr_t a1, uintptr_t a2, uintptr_t a3,
+	double f0, double f1, double f2, double f3, double f4, double f5, double f6, double f7) {
+	return (uintptr_t)go_block_invoke(b, a0, a1, a2, a3, f0, f1, f2, f3, f4, f5, f6, f7);
+}
+
+float go_block_float(struct go_block *b,
+	uintptr_t a0, uintptr_t a1, uintptr_t a2, uintptr_t a3,
+	double f0, double f1, double f2, double f3, double f4, double f5, double f6, double f7) {
+	uint64_t out = go_block_invoke(b, a0, a1, a2, a3, f0, f1, f2, f3, f4, f5, f6, f7);
+	float v;
+	memcpy(&v, &out, sizeof(v));
+	return v;
+}
+
+double go_block_double(struct go_block *b,
+	uintptr_t a0, uintptr_t a1, uintptr_t a2, uintptr_t a3,
+	double f0, double f1, double f2, double f3, double f4, double f5, double f6, double f7) {
+	uint64_t out = go_block_invoke(b, a0, a1, a2, a3, f0, f1, f2, f3, f4, f5, f6, f7);
+	double v;
+	memcpy(&v, &out, sizeof(v));
+	return v;
+}
@@ -0,0 +1,212 @@
+//go:build !objcfake
+// +build !objcfake
+
+package objc
+
+// #include "block.h"
+import "C"
+
+import (
+	"fmt"
+	"runtime/cgo"
+	"sync"
+	"unsafe"
+
+	"github.com/dennwc/go-apple/objc/encoding"
+)
+
+// Block is an Objective-C block object.
+//
+// See https://clang.llvm.org/docs/Block-ABI-Apple.html
+type Block struct {
+	Object
+}
+
+// blockDescriptors caches block descriptors for each signature. Descriptors are never freed.
+var blockDescriptors = struct {
+	sync.Mutex
+	bySig map[string]*C.struct_go_block_descriptor
+}{
+	bySig: make(map[string]*C.struct_go_block_descriptor),
+}
+
+// blockDescriptor returns a descriptor for blocks implemented in Go with a given signature.
+func blockDescriptor(sig string) *C.struct_go_block_descriptor {
+	blockDescriptors.Lock()
+	defer blockDescriptors.Unlock()
+	if d := blockDescriptors.bySig[sig]; d != nil {
+		return d
+	}
+	var d *C.struct_go_block_descriptor
+	d = (*C.struct_go_block_descriptor)(malloc(unsafe.Sizeof(*d)))
+	d.reserved = 0
+	d.size = C.ulong(unsafe.Sizeof(C.struct_go_block{}))
+	d.copy = (*[0]byte)(unsafe.Pointer(C.go_block_copy))
+	d.dispose = (*[0]byte)(unsafe.Pointer(C.go_block_dispose))
+	d.signature = C.CString(sig)
+	blockDescriptors.bySig[sig] = d
+	return d
+}
+
+// blockInvoke returns a trampoline that should be used as a block invoke function.
+func blockInvoke(ret string) unsafe.Pointer {
+	switch ret {
+	case "f":
+		return unsafe.Pointer(C.go_block_float)
+	case "d":
+		return unsafe.Pointer(C.go_block_double)
+	}
+	return unsafe.Pointer(C.go_block_int)
+}
+
+// NewBlock creates a block that calls a Go function.
+//
+// The function may accept arguments and return at most one value of types
+// listed in ClassBuilder.AddMethod. The block signature is derived from the function.
+// Same limitations on the number of arguments apply as for Object.Send.
+// The function must not panic, see ClassBuilder.AddMethod.
+//
+// The block is allocated on the heap and the caller owns the returned reference.
+// It must be released with Block.Release when it's no longer needed.
+//
+// See _Block_copy.
+func NewBlock(fnc interface{}) (Block, error) {
+	m, err := newGoBlock(fnc)
+	if err != nil {
+		return Block{}, fmt.Errorf("objc: block: %v", err)
+	}
+	isa := blockStackClass()
+	if isa == nil {
+		return Block{}, fmt.Errorf("objc: blocks are not supported by the runtime")
+	}
+	// create a block on the C heap that mimics a stack block and copy it
+	var b *C.struct_go_block
+	b = (*C.struct_go_block)(malloc(unsafe.Sizeof(*b)))
+	*b = C.struct_go_block{
+		isa:        isa,
+		flags:      C.GO_BLOCK_HAS_COPY_DISPOSE | C.GO_BLOCK_HAS_SIGNATURE,
+		invoke:     blockInvoke(m.ret),
+		descriptor: blockDescriptor(m.types()),
+	}
+	h := cgo.NewHandle(m)
+	b.handle = C.uintptr_t(h)
+	p := _Block_copy(unsafe.Pointer(b))
+	h.Delete()
+	free(unsafe.Pointer(b))
+	if p == nil {
+		return Block{}, fmt.Errorf("objc: cannot copy the block")
+	}
+	return Block{Object{id: cID(p)}}, nil
+}
+
+func (b Block) header() *C.struct_go_block {
+	return (*C.struct_go_block)(b.Pointer())
+}
+
+// Signature returns the type encoding of the block. The block itself is the first argument.
+// It returns an empty string if the block has no signature.
+func (b Block) Signature() string {
+	if b.IsNil() {
+		return ""
+	}
+	h := b.header()
+	if h.flags&C.GO_BLOCK_HAS_SIGNATURE == 0 || h.descriptor == nil {
+		return ""
+	}
+	var v C.ulong
+	off := 2 * unsafe.Sizeof(v)
+	if h.flags&C.GO_BLOCK_HAS_COPY_DISPOSE != 0 {
+		// copy and dispose helpers
+		off += 2 * unsafe.Sizeof(uintptr(0))
+	}
+	s := *(**C.char)(incPtr(unsafe.Pointer(h.descriptor), off))
+	if s == nil {
+		return ""
+	}
+	return C.GoString(s)
+}
+
+// Call invokes the block with arguments. The type encoding is taken from the block signature.
+// See Object.Send for supported arguments and results.
+func (b Block) Call(args ...interface{}) (interface{}, error) {
+	types := b.Signature()
+	if types == "" && !b.IsNil() {
+		return nil, fmt.Errorf("objc: block has no signature")
+	}
+	return b.CallTypes(types, args...)
+}
+
+// CallTypes invokes the block with arguments using a given type encoding.
+// It can be used for blocks without a signature. The encoding must include
+// the block itself as the first argument, for example "v@?i" for a block
+// that accepts an int.
+func (b Block) CallTypes(types string, args ...interface{}) (interface{}, error) {
+	if b.IsNil() {
+		return nil, fmt.Errorf("objc: call nil block")
+	}
+	sig, err := encoding.ParseMethod(types)
+	if err != nil {
+		return nil, fmt.Errorf("objc: block: %v", err)
+	} else if len(sig.Args) < 1 {
+		return nil, fmt.Errorf("objc: block: invalid type encoding: %q", types)
+	}
+	// skip the block itself
+	targs := sig.Args[1:]
+	if len(args) != len(targs) {
+		return nil, fmt.Errorf("objc: block: expected %d arguments, got %d", len(targs), len(args))
+	}
+	var a callArgs
+	for i, arg := range targs {
+		if err := a.add(arg.Type.String(), args[i]); err != nil {
+			return nil, fmt.Errorf("objc: block: argument %d: %v", i, err)
+		}
+	}
+	// blocks only have one implicit argument, thus the first integer argument
+	// is passed in place of the selector and the rest are shifted
+	var sh callArgs
+	copy(sh.ints[:], a.ints[1:])
+	sh.floats = a.floats
+	first := *(*unsafe.Pointer)(unsafe.Pointer(&a.ints[0]))
+	return sh.call(b.header().invoke, b.Pointer(), first, sig.Return.String())
+}
+
+// Copy copies the block to the heap, or retains it if it's already there.
+// The caller owns the returned reference.
+//
+// See _Block_copy.
+func (b Block) Copy() Block {
+	if b.IsNil() {
+		return b
+	}
+	return Block{Object{id: cID(_Block_copy(b.Pointer()))}}
+}
+
+// Release releases the block.
+//
+// See _Block_release.
+func (b Block) Release() {
+	if b.IsNil() {
+		return
+	}
+	_Block_release(b.Pointer())
+}
+
+//export goBlockCopy
+func goBlockCopy(h C.uintptr_t) C.uintptr_t {
+	return C.uintptr_t(cgo.NewHandle(cgo.Handle(h).Value()))
+}
+
+//export goBlockDispose
+func goBlockDispose(h C.uintptr_t) {
+	cgo.Handle(h).Delete()
+}
+
+//export goObjcBlock
+func goObjcBlock(h C.uintptr_t, ints *C.uintptr_t, floats *C.double, out *C.uint64_t) {
+	defer recoverCallback(nil, (*uint64)(unsafe.Pointer(out)))
+	m := cgo.Handle(h).Value().(*goMethod)
+	*out = C.uint64_t(m.call(Object{}, Selector{},
+		(*[maxIntArgs]uint64)(unsafe.Pointer(ints)),
+		(*[maxFloatArgs]float64)(unsafe.Pointer(floats)),
+	))
+}
@@ -0,0 +1,46 @@
+#ifndef GO_OBJC_BLOCK_H
+#define GO_OBJC_BLOCK_H
+
+#include <stdint.h>
+
+// Block layout as defined by the Blocks ABI.
+// See https://clang.llvm.org/docs/Block-ABI-Apple.html
+
+enum {
+	GO_BLOCK_HAS_COPY_DISPOSE = (1 << 25),
+	GO_BLOCK_HAS_SIGNATURE    = (1 << 30),
+};
+
+struct go_block_descriptor {
+	unsigned long reserved;
+	unsigned long size;
+	void (*copy)(void *dst, const void *src);
+	void (*dispose)(const void *src);
+	const char *signature;
+};
+
+// go_block is a block that calls a Go function. Foreign blocks only share the header with it.
+struct go_block {
+	void *isa;
+	int flags;
+	int reserved;
+	void *invoke;
+	struct go_block_descriptor *descriptor;
+	// imported variables
+	uintptr_t handle;
+};
+
+void go_block_copy(void *dst, const void *src);
+void go_block_dispose(const void *src);
+
+uintptr_t go_block_int(struct go_block *b,
+	uintptr_t a0, uintptr_t a1, uintptr_t a2, uintptr_t a3,
+	double f0, double f1, double f2, double f3, double f4, double f5, double f6, double f7);
+float go_block_float(struct go_block *b,
+	uintptr_t a0, uintptr_t a1, uintptr_t a2, uintptr_t a3,
+	double f0, double f1, double f2, double f3, double f4, double f5, double f6, double f7);
+double go_block_double(struct go_block *b,
+	uintptr_t a0, uintptr_t a1, uintptr_t a2, uintptr_t a3,
+	double f0, double f1, double f2, double f3, double f4, double f5, double f6, double f7);
+
+#endif // GO_OBJC_BLOCK_H
@@ -0,0 +1,49 @@
+//go:build objcfake
+// +build objcfake
+
+package objc
+
+import "fmt"
+
+// Block is an Objective-C block object.
+//
+// Blocks are not supported by the fake runtime.
+type Block struct {
+	Object
+}
+
+// NewBlock creates a block that calls a Go function.
+//
+// The fake runtime doesn't support blocks, thus it only checks the function and returns an error.
+func NewBlock(fnc interface{}) (Block, error) {
+	if _, err := newGoBlock(fnc); err != nil {
+		return Block{}, fmt.Errorf("objc: block: %v", err)
+	}
+	return Block{}, fmt.Errorf("objc: blocks are not supported by the runtime")
+}
+
+// Signature returns the type encoding of the block. It always returns an empty string.
+func (b Block) Signature() string {
+	return ""
+}
+
+// Call invokes the block with arguments. It always returns an error.
+func (b Block) Call(args ...interface{}) (interface{}, error) {
+	return b.CallTypes("", args...)
+}
+
+// CallTypes invokes the block with arguments using a given type encoding. It always returns an error.
+func (b Block) CallTypes(types string, args ...interface{}) (interface{}, error) {
+	if b.IsNil() {
+		return nil, fmt.Errorf("objc: call nil block")
+	}
+	return nil, fmt.Errorf("objc: blocks are not supported by the runtime")
+}
+
+// Copy copies the block. It returns the block itself.
+func (b Block) Copy() Block {
+	return b
+}
+
+// Release releases the block. It does nothing.
+func (b Block) Release() {}
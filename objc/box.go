@@ -0,0 +1,74 @@
+package objc
+
+import (
+	"fmt"
+	"sync"
+)
+
+// goBox is the GoBox class. Objects of this class hold Go values.
+// The value is released when the object is deallocated.
+type goBox struct {
+	Object `objc:"GoBox : NSObject"`
+	value  interface{}
+}
+
+var boxClass struct {
+	once  sync.Once
+	class *Class
+	err   error
+}
+
+// BoxClass returns the GoBox class, registering it on the first call.
+// The class is derived from NSObject, thus NSObject must be available in the runtime.
+func BoxClass() (*Class, error) {
+	boxClass.once.Do(func() {
+		if GetClass("NSObject") == nil {
+			boxClass.err = fmt.Errorf("objc: cannot store Go values: NSObject class is not available")
+			return
+		}
+		boxClass.class, boxClass.err = RegisterClass((*goBox)(nil))
+	})
+	return boxClass.class, boxClass.err
+}
+
+// Box creates a GoBox object that holds a Go value. The value can be retrieved with Unbox.
+//
+// The object only holds a handle of the value, thus it can be passed to Objective-C code,
+// for example stored in collections, without violating cgo pointer passing rules.
+// The value is kept alive until the object is deallocated.
+//
+// The caller owns the returned reference, see Adopt.
+func Box(v interface{}) (Object, error) {
+	c, err := BoxClass()
+	if err != nil {
+		return Object{}, err
+	}
+	res, err := c.Send(RegisterSelector("alloc"))
+	if err != nil {
+		return Object{}, err
+	}
+	res, err = res.(Object).Send(RegisterSelector("init"))
+	if err != nil {
+		return Object{}, err
+	}
+	obj, _ := res.(Object)
+	b, ok := obj.GoValue().(*goBox)
+	if !ok {
+		return Object{}, fmt.Errorf("objc: cannot create an object for a Go value")
+	}
+	b.value = v
+	return obj, nil
+}
+
+// Unbox returns a Go value held by a GoBox object created with Box.
+// It returns false if the object is not a GoBox.
+func Unbox(o Object) (interface{}, bool) {
+	if o.IsNil() {
+		return nil, false
+	}
+	b, ok := o.GoValue().(*goBox)
+	if !ok {
+		return nil, false
+	}
+	return b.value, true
+}
@@ -0,0 +1,40 @@
+#ifndef GO_OBJC_CALL_H
+#define GO_OBJC_CALL_H
+
+#include <stdint.h>
+
+// Functions are called with all argument registers filled. Since integer and floating point
+// arguments are assigned to registers independently, the callee will only read the ones it expects.
+typedef uintptr_t (*go_imp_int)(void*, void*, uintptr_t, uintptr_t, uintptr_t, uintptr_t,
+	double, double, double, double, double, double, double, double);
+typedef float (*go_imp_float)(void*, void*, uintptr_t, uintptr_t, uintptr_t, uintptr_t,
+	double, double, double, double, double, double, double, double);
+typedef double (*go_imp_double)(void*, void*, uintptr_t, uintptr_t, uintptr_t, uintptr_t,
+	double, double, double, double, double, double, double, double);
+
+static uintptr_t go_call_int(void *imp, void *self, void *sel, uintptr_t *a, double *f) {
+	return ((go_imp_int)imp)(self, sel, a[0], a[1], a[2], a[3], f[0], f[1], f[2], f[3], f[4], f[5], f[6], f[7]);
+}
+
+static float go_call_float(void *imp, void *self, void *sel, uintptr_t *a, double *f) {
+	return ((go_imp_float)imp)(self, sel, a[0], a[1], a[2], a[3], f[0], f[1], f[2], f[3], f[4], f[5], f[6], f[7]);
+}
+
+static double go_call_double(void *imp, void *self, void *sel, uintptr_t *a, double *f) {
+	return ((go_imp_double)imp)(self, sel, a[0], a[1], a[2], a[3], f[0], f[1], f[2], f[3], f[4], f[5], f[6], f[7]);
+}
+
+// Same as above, but Objective-C exceptions are caught and converted to Go values.
+// Exception handle is stored to exc, or it's set to zero if no exception was raised.
+uintptr_t go_try_call_int(void *imp, void *self, void *sel, uintptr_t *a, double *f, uintptr_t *exc);
+float go_try_call_float(void *imp, void *self, void *sel, uintptr_t *a, double *f, uintptr_t *exc);
+double go_try_call_double(void *imp, void *self, void *sel, uintptr_t *a, double *f, uintptr_t *exc);
+
+// Exceptions are only caught if the compiler supports the exception syntax, see exception.m.
+#if defined(__clang__) || defined(GO_OBJC_EXCEPTIONS)
+#define GO_OBJC_CATCHES 1
+#else
+#define GO_OBJC_CATCHES 0
+#endif
+
+#endif // GO_OBJC_CALL_H
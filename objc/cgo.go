@@ -1,9 +1,25 @@
+//go:build !objcfake
+// +build !objcfake
+
 package objc
 
-// #include <stdlib.h>
+/*
+#include <stdlib.h>
+#include <stdint.h>
+#include <pthread.h>
+
+#include "call.h"
+
+static uintptr_t go_thread_id() {
+	return (uintptr_t)pthread_self();
+}
+*/
 import "C"
 
-import "unsafe"
+import (
+	"runtime/cgo"
+	"unsafe"
+)
 
 func free(p unsafe.Pointer) {
 	C.free(p)
@@ -17,6 +33,89 @@ func malloc(sz uintptr) unsafe.Pointer {
 	return C.malloc(C.size_t(sz))
 }
 
-func incPtr(p unsafe.Pointer, i uintptr) unsafe.Pointer {
-	return unsafe.Pointer(uintptr(p) + i)
+// goStrings copies a list of C strings and frees the list. Strings are not freed.
+func goStrings(buf **C.char, n C.uint) []string {
+	if buf == nil {
+		return nil
+	}
+	out := make([]string, 0, n)
+	for _, s := range (*[1 << 28]*C.char)(unsafe.Pointer(buf))[:n:n] {
+		out = append(out, C.GoString(s))
+	}
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+// hasPrefix checks if a C string starts with a given prefix without copying it.
+func hasPrefix(s *C.char, prefix string) bool {
+	if s == nil {
+		return prefix == ""
+	}
+	p := unsafe.Pointer(s)
+	for i := 0; i < len(prefix); i++ {
+		b := *(*byte)(incPtr(p, uintptr(i)))
+		if b != prefix[i] {
+			// also stops at the terminating zero
+			return false
+		}
+	}
+	return true
+}
+
+func (a *callArgs) cInts() *C.uintptr_t {
+	return (*C.uintptr_t)(unsafe.Pointer(&a.ints[0]))
+}
+
+func (a *callArgs) cFloats() *C.double {
+	return (*C.double)(unsafe.Pointer(&a.floats[0]))
+}
+
+// callInt calls a function that returns an integer or a pointer.
+// Objective-C exceptions raised by the function are returned as *Exception.
+func callInt(imp, self, sel unsafe.Pointer, a *callArgs) (uint64, error) {
+	var exc C.uintptr_t
+	v := uint64(C.go_try_call_int(imp, self, sel, a.cInts(), a.cFloats(), &exc))
+	return v, takeException(uintptr(exc))
+}
+
+// callFloat calls a function that returns a float.
+// Objective-C exceptions raised by the function are returned as *Exception.
+func callFloat(imp, self, sel unsafe.Pointer, a *callArgs) (float32, error) {
+	var exc C.uintptr_t
+	v := float32(C.go_try_call_float(imp, self, sel, a.cInts(), a.cFloats(), &exc))
+	return v, takeException(uintptr(exc))
+}
+
+// callDouble calls a function that returns a double.
+// Objective-C exceptions raised by the function are returned as *Exception.
+func callDouble(imp, self, sel unsafe.Pointer, a *callArgs) (float64, error) {
+	var exc C.uintptr_t
+	v := float64(C.go_try_call_double(imp, self, sel, a.cInts(), a.cFloats(), &exc))
+	return v, takeException(uintptr(exc))
+}
+
+// catchesExceptions reports if callInt, callFloat and callDouble catch Objective-C exceptions.
+func catchesExceptions() bool {
+	return C.GO_OBJC_CATCHES != 0
+}
+
+// threadID returns an identifier of the current OS thread.
+func threadID() uintptr {
+	return uintptr(C.go_thread_id())
+}
+
+// newHandle returns a handle for a Go value that can be stored in C memory.
+// The handle must be deleted with deleteHandle.
+func newHandle(v interface{}) uintptr {
+	return uintptr(cgo.NewHandle(v))
+}
+
+// handleValue returns a Go value of a handle.
+func handleValue(h uintptr) interface{} {
+	return cgo.Handle(h).Value()
+}
+
+// deleteHandle invalidates the handle.
+func deleteHandle(h uintptr) {
+	cgo.Handle(h).Delete()
 }
@@ -0,0 +1,148 @@
+//go:build objcfake
+// +build objcfake
+
+package objc
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"unsafe"
+)
+
+// fakeHeap keeps memory returned by malloc alive until it's freed.
+var fakeHeap = struct {
+	sync.Mutex
+	blocks map[unsafe.Pointer][]uint64
+}{
+	blocks: make(map[unsafe.Pointer][]uint64),
+}
+
+// malloc allocates zeroed Go memory. The memory is not scanned by the garbage collector,
+// thus pointers stored in it must be kept alive by other means.
+func malloc(sz uintptr) unsafe.Pointer {
+	buf := make([]uint64, (sz+7)/8+1)
+	p := unsafe.Pointer(&buf[0])
+	fakeHeap.Lock()
+	fakeHeap.blocks[p] = buf
+	fakeHeap.Unlock()
+	return p
+}
+
+func free(p unsafe.Pointer) {
+	fakeHeap.Lock()
+	delete(fakeHeap.blocks, p)
+	fakeHeap.Unlock()
+}
+
+// fakeIMP is a method implementation in the fake runtime.
+type fakeIMP struct {
+	m       *goMethod // nil for trampolines of methods added from Go
+	depth   int       // depth of the class of the method added from Go
+	forward bool      // forwards the message, see fakeForward
+}
+
+// goTrampolines are used as IMPs of methods added from Go, one for each class depth.
+// Same as the C trampolines, they find the implementation by the class depth and the selector.
+var goTrampolines = struct {
+	sync.Mutex
+	byDepth []*fakeIMP
+}{}
+
+// impTrampoline returns a trampoline that should be used as an IMP for Go methods with a given
+// result type, added to a class at a given depth in the hierarchy.
+func impTrampoline(ret string, depth int) unsafe.Pointer {
+	goTrampolines.Lock()
+	defer goTrampolines.Unlock()
+	for len(goTrampolines.byDepth) <= depth {
+		goTrampolines.byDepth = append(goTrampolines.byDepth, &fakeIMP{depth: len(goTrampolines.byDepth)})
+	}
+	return unsafe.Pointer(goTrampolines.byDepth[depth])
+}
+
+// newFakeIMP returns an IMP that calls a given Go method directly.
+func newFakeIMP(m *goMethod) unsafe.Pointer {
+	return unsafe.Pointer(&fakeIMP{m: m})
+}
+
+// fakeCall calls the implementation and returns the result register value.
+// Methods raise exceptions by panicking with *Exception, which are returned as errors.
+func fakeCall(imp, self, sel unsafe.Pointer, a *callArgs) (w uint64, err error) {
+	if imp == nil {
+		return 0, fmt.Errorf("objc: call nil implementation")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Exception)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	f := (*fakeIMP)(imp)
+	if f.forward {
+		return fakeForward(self, sel, a)
+	}
+	if f.m == nil {
+		return callGoImp(f.depth, self, sel, &a.ints, &a.floats), nil
+	}
+	return f.m.call(Object{id: cID(self)}, Selector{sel: cSEL(sel)}, &a.ints, &a.floats), nil
+}
+
+// callInt calls a function that returns an integer or a pointer.
+func callInt(imp, self, sel unsafe.Pointer, a *callArgs) (uint64, error) {
+	return fakeCall(imp, self, sel, a)
+}
+
+// callFloat calls a function that returns a float.
+func callFloat(imp, self, sel unsafe.Pointer, a *callArgs) (float32, error) {
+	w, err := fakeCall(imp, self, sel, a)
+	return math.Float32frombits(uint32(w)), err
+}
+
+// callDouble calls a function that returns a double.
+func callDouble(imp, self, sel unsafe.Pointer, a *callArgs) (float64, error) {
+	w, err := fakeCall(imp, self, sel, a)
+	return math.Float64frombits(w), err
+}
+
+// fakeHandles holds Go values referenced by handles.
+var fakeHandles = struct {
+	sync.Mutex
+	last   uintptr
+	values map[uintptr]interface{}
+}{
+	values: make(map[uintptr]interface{}),
+}
+
+// newHandle returns a handle for a Go value that can be stored in object memory.
+// The handle must be deleted with deleteHandle.
+func newHandle(v interface{}) uintptr {
+	fakeHandles.Lock()
+	defer fakeHandles.Unlock()
+	fakeHandles.last++
+	fakeHandles.values[fakeHandles.last] = v
+	return fakeHandles.last
+}
+
+// handleValue returns a Go value of a handle.
+func handleValue(h uintptr) interface{} {
+	fakeHandles.Lock()
+	defer fakeHandles.Unlock()
+	v, ok := fakeHandles.values[h]
+	if !ok {
+		panic("objc: invalid handle")
+	}
+	return v
+}
+
+// deleteHandle invalidates the handle.
+func deleteHandle(h uintptr) {
+	fakeHandles.Lock()
+	defer fakeHandles.Unlock()
+	if _, ok := fakeHandles.values[h]; !ok {
+		panic("objc: invalid handle")
+	}
+	delete(fakeHandles.values, h)
+}
@@ -0,0 +1,111 @@
+package objc
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// classBuffers holds buffers for class lists that are reused between calls to Classes.
+var classBuffers = sync.Pool{
+	New: func() interface{} {
+		return new([]cClass)
+	},
+}
+
+// readClasses fills the buffer with all registered classes, growing it if necessary.
+func readClasses(buf []cClass) []cClass {
+	for {
+		n := objc_getClassList(nil)
+		if n == 0 {
+			return buf[:0]
+		}
+		if cap(buf) < n {
+			// leave some space for classes registered later
+			buf = make([]cClass, n+n/8)
+		}
+		buf = buf[:cap(buf)]
+		n = objc_getClassList(buf)
+		if n <= len(buf) {
+			return buf[:n]
+		}
+		// more classes were registered in the meantime
+	}
+}
+
+// Classes calls the function for each registered class until it returns false.
+//
+// Unlike ListClasses, it reuses internal buffers between calls.
+//
+// See objc_getClassList.
+func Classes(fnc func(c Class) bool) {
+	bp := classBuffers.Get().(*[]cClass)
+	defer classBuffers.Put(bp)
+	*bp = readClasses(*bp)
+	for _, c := range *bp {
+		if !fnc(Class{class: c}) {
+			return
+		}
+	}
+}
+
+// ClassesWithPrefix calls the function for each registered class with a given name prefix until it returns false.
+func ClassesWithPrefix(prefix string, fnc func(c Class) bool) {
+	Classes(func(c Class) bool {
+		if !class_hasPrefix(c.class, prefix) {
+			return true
+		}
+		return fnc(c)
+	})
+}
+
+// classGen is incremented each time classes are registered or disposed from Go.
+var classGen uint64
+
+func invalidateClasses() {
+	atomic.AddUint64(&classGen, 1)
+}
+
+var classCache struct {
+	sync.Mutex
+	classes []Class
+	count   int
+	gen     uint64
+	image   uint64
+}
+
+// CachedClasses returns a list of all registered classes.
+//
+// The list is cached and refreshed only if the number of classes changes, if new images are loaded,
+// or if classes are registered or disposed with this package. The returned slice must not be modified.
+//
+// Classes registered and disposed by other code are only noticed if the number of classes changes,
+// thus the list may be stale if the number stays the same; use InvalidateClassCache in this case.
+// GNU runtimes do not report loaded images, thus the list is not cached on them.
+func CachedClasses() []Class {
+	image, ok := imageGeneration()
+	if !ok {
+		return ListClasses()
+	}
+	count := objc_getClassList(nil)
+	gen := atomic.LoadUint64(&classGen)
+
+	classCache.Lock()
+	defer classCache.Unlock()
+	if classCache.classes != nil && classCache.count == count &&
+		classCache.gen == gen && classCache.image == image {
+		return classCache.classes
+	}
+	classCache.classes = ListClasses()
+	if classCache.classes == nil {
+		classCache.classes = []Class{}
+	}
+	classCache.count = len(classCache.classes)
+	classCache.gen = gen
+	classCache.image = image
+	return classCache.classes
+}
+
+// InvalidateClassCache forces CachedClasses to reload the list of classes on the next call.
+func InvalidateClassCache() {
+	invalidateClasses()
+}
@@ -0,0 +1,165 @@
+package objc
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/dennwc/go-apple/objc/encoding"
+)
+
+// AllocateClassPair creates a new class and metaclass. The class must be registered
+// with ClassBuilder.Register before it can be used.
+//
+// See objc_allocateClassPair.
+func AllocateClassPair(super *Class, name string) (*ClassBuilder, error) {
+	var sc cClass
+	if super != nil {
+		sc = super.class
+	}
+	c := objc_allocateClassPair(sc, name)
+	if c == nil {
+		return nil, fmt.Errorf("objc: cannot allocate class %q", name)
+	}
+	return &ClassBuilder{class: Class{class: c}}, nil
+}
+
+// DisposeClassPair destroys a class and its associated metaclass.
+// It also removes all Go methods and forwarding handlers registered for the class.
+//
+// See objc_disposeClassPair.
+func DisposeClassPair(c *Class) {
+	if !c.Valid() {
+		return
+	}
+	removeGoMethods(c.class)
+	removeGoMethods(object_getClass(c.Object().id))
+	removeForwarder(c.class)
+	objc_disposeClassPair(c.class)
+	invalidateClasses()
+}
+
+// ClassBuilder is a class allocated with AllocateClassPair that is not yet registered.
+type ClassBuilder struct {
+	class      Class
+	registered bool
+}
+
+// Class returns the class that is being built.
+func (b *ClassBuilder) Class() *Class {
+	return &b.class
+}
+
+func addMethod(c cClass, sel Selector, fnc interface{}) error {
+	if sel.IsNil() {
+		return fmt.Errorf("objc: add method with nil selector")
+	}
+	m, err := newGoMethod(fnc)
+	if err != nil {
+		return fmt.Errorf("objc: %q: %v", sel.Name(), err)
+	}
+	return addGoMethod(c, sel, m)
+}
+
+func addGoMethod(c cClass, sel Selector, m *goMethod) error {
+	name := sel.Name()
+	imp, err := m.imp(c)
+	if err != nil {
+		return fmt.Errorf("objc: %q: %v", name, err)
+	}
+	// the method must be registered before it's added, since it may be called right away
+	prev := getGoMethod(c, name)
+	setGoMethod(c, name, m)
+	if !class_addMethod(c, sel.sel, imp, m.types()) {
+		// keep the existing method, if any
+		setGoMethod(c, name, prev)
+		return fmt.Errorf("objc: class %s already implements %q", class_getName(c), name)
+	}
+	return nil
+}
+
+// AddMethod adds an instance method implemented in Go.
+//
+// The function must accept the receiver Object and the Selector as the first two
+// arguments, followed by the method arguments. It may return at most one value.
+// Arguments and the result may have the following types: bool, signed and unsigned
+// integers, floats, Object, *Class, Selector and unsafe.Pointer.
+// The type encoding of the method is derived from the function signature.
+//
+// Same limitations on the number of arguments apply as for Object.Send.
+// The function must not panic: panics are recovered and logged, and the caller receives a zero result.
+//
+// See class_addMethod.
+func (b *ClassBuilder) AddMethod(sel Selector, fnc interface{}) error {
+	return addMethod(b.class.class, sel, fnc)
+}
+
+// AddClassMethod adds a class method implemented in Go. See AddMethod for details.
+func (b *ClassBuilder) AddClassMethod(sel Selector, fnc interface{}) error {
+	return addMethod(object_getClass(b.class.Object().id), sel, fnc)
+}
+
+// AddMethod adds an instance method implemented in Go to a registered class.
+// It can be used to add methods from a +resolveInstanceMethod: handler, see ClassBuilder.SetResolveInstanceMethod.
+// See ClassBuilder.AddMethod for details.
+func (c *Class) AddMethod(sel Selector, fnc interface{}) error {
+	if !c.Valid() {
+		return fmt.Errorf("objc: add method to nil class")
+	}
+	return addMethod(c.class, sel, fnc)
+}
+
+// AddClassMethod adds a class method implemented in Go to a registered class.
+// See ClassBuilder.AddMethod for details.
+func (c *Class) AddClassMethod(sel Selector, fnc interface{}) error {
+	if !c.Valid() {
+		return fmt.Errorf("objc: add method to nil class")
+	}
+	return addMethod(object_getClass(c.Object().id), sel, fnc)
+}
+
+// AddIvar adds an instance variable with a given type encoding to the class.
+// Instance variables can only be added before the class is registered.
+//
+// See class_addIvar.
+func (b *ClassBuilder) AddIvar(name, types string) error {
+	if b.registered {
+		return fmt.Errorf("objc: cannot add ivar %q to registered class %s", name, b.class.Name())
+	}
+	t, err := encoding.Parse(types)
+	if err != nil {
+		return fmt.Errorf("objc: ivar %q: %v", name, err)
+	}
+	size, align := t.Size(), t.Align()
+	if align == 0 || align&(align-1) != 0 {
+		return fmt.Errorf("objc: ivar %q: invalid alignment: %d", name, align)
+	}
+	if !class_addIvar(b.class.class, name, size, uint8(bits.TrailingZeros(uint(align))), types) {
+		return fmt.Errorf("objc: cannot add ivar %q to class %s", name, b.class.Name())
+	}
+	return nil
+}
+
+// AddProtocol adds a protocol to the class.
+//
+// See class_addProtocol.
+func (b *ClassBuilder) AddProtocol(p *Protocol) error {
+	if !p.Valid() {
+		return fmt.Errorf("objc: add nil protocol to class %s", b.class.Name())
+	}
+	if !class_addProtocol(b.class.class, p.proto) {
+		return fmt.Errorf("objc: cannot add protocol %s to class %s", p.Name(), b.class.Name())
+	}
+	return nil
+}
+
+// Register registers the class with the runtime. After this call the class can be instantiated.
+//
+// See objc_registerClassPair.
+func (b *ClassBuilder) Register() *Class {
+	if !b.registered {
+		objc_registerClassPair(b.class.class)
+		b.registered = true
+		invalidateClasses()
+	}
+	return &b.class
+}
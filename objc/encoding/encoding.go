@@ -0,0 +1,428 @@
+// Package encoding implements a parser for Objective-C type encodings.
+//
+// See https://developer.apple.com/library/archive/documentation/Cocoa/Conceptual/ObjCRuntimeGuide/Articles/ocrtTypeEncodings.html
+package encoding
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// Type is a node of the type encoding AST.
+type Type interface {
+	// String returns the type encoding of the type.
+	String() string
+	// Size returns the size of the type in bytes.
+	Size() uintptr
+	// Align returns the alignment of the type in bytes.
+	Align() uintptr
+}
+
+const ptrSize = unsafe.Sizeof(uintptr(0))
+
+// longSize is the size of 'l' encoding, see Long. Apple runtime always treats it as 32 bit,
+// while GNU runtimes use the native size.
+var longSize = func() uintptr {
+	if runtime.GOOS == "darwin" {
+		return 4
+	}
+	return ptrSize
+}()
+
+// longDoubleSize is the size of 'D' encoding.
+var longDoubleSize = func() uintptr {
+	switch runtime.GOARCH {
+	case "amd64":
+		return 16
+	case "386":
+		return 12
+	case "arm64":
+		if runtime.GOOS == "darwin" {
+			return 8
+		}
+		return 16
+	}
+	return 8
+}()
+
+// longDoubleAlign is the alignment of 'D' encoding.
+var longDoubleAlign = func() uintptr {
+	switch runtime.GOARCH {
+	case "amd64":
+		return 16
+	case "386":
+		return 4
+	case "arm64":
+		if runtime.GOOS == "darwin" {
+			return 8
+		}
+		return 16
+	}
+	return 8
+}()
+
+// int64Align is the alignment of 64 bit integers and doubles. The i386 ABI only aligns them to 4 bytes.
+var int64Align = func() uintptr {
+	if runtime.GOARCH == "386" {
+		return 4
+	}
+	return 8
+}()
+
+// Basic is a type that is encoded with a single character.
+type Basic byte
+
+const (
+	Char             = Basic('c')
+	Int              = Basic('i')
+	Short            = Basic('s')
+	Long             = Basic('l')
+	LongLong         = Basic('q')
+	Int128           = Basic('t')
+	UnsignedChar     = Basic('C')
+	UnsignedInt      = Basic('I')
+	UnsignedShort    = Basic('S')
+	UnsignedLong     = Basic('L')
+	UnsignedLongLong = Basic('Q')
+	UnsignedInt128   = Basic('T')
+	Float            = Basic('f')
+	Double           = Basic('d')
+	LongDouble       = Basic('D')
+	Bool             = Basic('B')
+	Void             = Basic('v')
+	CString          = Basic('*')
+	Class            = Basic('#')
+	Selector         = Basic(':')
+	Unknown          = Basic('?')
+)
+
+const basicTypes = "cislqtCISLQTfdDBv*#:?"
+
+func (t Basic) String() string {
+	return string(rune(t))
+}
+
+func (t Basic) Size() uintptr {
+	switch t {
+	case Char, UnsignedChar, Bool:
+		return 1
+	case Short, UnsignedShort:
+		return 2
+	case Int, UnsignedInt, Float:
+		return 4
+	case Long, UnsignedLong:
+		return longSize
+	case LongLong, UnsignedLongLong, Double:
+		return 8
+	case Int128, UnsignedInt128:
+		return 16
+	case LongDouble:
+		return longDoubleSize
+	case CString, Class, Selector:
+		return ptrSize
+	}
+	return 0
+}
+
+// Align returns the alignment of the type according to the C ABI, which may differ from its size.
+func (t Basic) Align() uintptr {
+	switch t {
+	case Void, Unknown:
+		return 1
+	case LongLong, UnsignedLongLong, Double:
+		return int64Align
+	case LongDouble:
+		return longDoubleAlign
+	}
+	return t.Size()
+}
+
+// IsInteger checks if the type is a signed or unsigned integer.
+func (t Basic) IsInteger() bool {
+	switch t {
+	case Char, Int, Short, Long, LongLong, Int128,
+		UnsignedChar, UnsignedInt, UnsignedShort, UnsignedLong, UnsignedLongLong, UnsignedInt128:
+		return true
+	}
+	return false
+}
+
+// IsUnsigned checks if the type is an unsigned integer.
+func (t Basic) IsUnsigned() bool {
+	switch t {
+	case UnsignedChar, UnsignedInt, UnsignedShort, UnsignedLong, UnsignedLongLong, UnsignedInt128:
+		return true
+	}
+	return false
+}
+
+// IsFloat checks if the type is a floating point number.
+func (t Basic) IsFloat() bool {
+	switch t {
+	case Float, Double, LongDouble:
+		return true
+	}
+	return false
+}
+
+// Object is an object pointer (id), optionally with a class name.
+type Object struct {
+	ClassName string
+}
+
+func (t Object) String() string {
+	if t.ClassName == "" {
+		return "@"
+	}
+	return `@"` + t.ClassName + `"`
+}
+
+func (t Object) Size() uintptr  { return ptrSize }
+func (t Object) Align() uintptr { return ptrSize }
+
+// Block is a pointer to a block object.
+type Block struct{}
+
+func (t Block) String() string { return "@?" }
+func (t Block) Size() uintptr  { return ptrSize }
+func (t Block) Align() uintptr { return ptrSize }
+
+// Pointer is a pointer to a type.
+type Pointer struct {
+	Elem Type
+}
+
+func (t Pointer) String() string { return "^" + t.Elem.String() }
+func (t Pointer) Size() uintptr  { return ptrSize }
+func (t Pointer) Align() uintptr { return ptrSize }
+
+// Complex is a complex number of a given floating point type.
+type Complex struct {
+	Elem Type
+}
+
+func (t Complex) String() string { return "j" + t.Elem.String() }
+func (t Complex) Size() uintptr  { return 2 * t.Elem.Size() }
+func (t Complex) Align() uintptr { return t.Elem.Align() }
+
+// Array is a fixed-size array.
+type Array struct {
+	Len  int
+	Elem Type
+}
+
+func (t Array) String() string {
+	return "[" + strconv.Itoa(t.Len) + t.Elem.String() + "]"
+}
+
+func (t Array) Size() uintptr  { return uintptr(t.Len) * t.Elem.Size() }
+func (t Array) Align() uintptr { return t.Elem.Align() }
+
+// BitField is a bit field of a structure.
+//
+// Apple runtime only encodes the number of bits. GNU runtime also encodes
+// the position of the field and the storage type.
+type BitField struct {
+	Pos  int  // bit position of the field; GNU only
+	Type Type // storage type of the field; GNU only
+	Bits int  // number of bits
+}
+
+func (t BitField) String() string {
+	if t.Type == nil {
+		return "b" + strconv.Itoa(t.Bits)
+	}
+	return "b" + strconv.Itoa(t.Pos) + t.Type.String() + strconv.Itoa(t.Bits)
+}
+
+// storage returns the storage type of the bit field.
+// Unsigned int is assumed if it's not known.
+func (t BitField) storage() Type {
+	if t.Type == nil {
+		return UnsignedInt
+	}
+	return t.Type
+}
+
+func (t BitField) Size() uintptr  { return t.storage().Size() }
+func (t BitField) Align() uintptr { return t.storage().Align() }
+
+// Field is a field of a structure or a union.
+type Field struct {
+	Name string // optional
+	Type Type
+}
+
+// Struct is a structure. Fields are nil if the structure is opaque.
+type Struct struct {
+	Name   string
+	Fields []Field
+}
+
+func (t Struct) String() string {
+	return printFields('{', t.Name, t.Fields, '}')
+}
+
+// Offsets returns offsets of all fields in the structure.
+// For bit fields, the offset of the storage unit is returned.
+func (t Struct) Offsets() []uintptr {
+	offs, _, _ := t.layout()
+	return offs
+}
+
+// layout computes field offsets, size and alignment of the structure.
+func (t Struct) layout() ([]uintptr, uintptr, uintptr) {
+	var (
+		offs  = make([]uintptr, 0, len(t.Fields))
+		bits  uintptr // current offset in bits
+		align uintptr = 1
+	)
+	for _, f := range t.Fields {
+		fa := f.Type.Align()
+		if fa > align {
+			align = fa
+		}
+		if bf, ok := f.Type.(BitField); ok {
+			// start a new storage unit if the field doesn't fit into the current one
+			unit := 8 * bf.Size()
+			n := uintptr(bf.Bits)
+			if unit != 0 && n != 0 && bits/unit != (bits+n-1)/unit {
+				bits = alignUp(bits, unit)
+			}
+			if unit != 0 {
+				offs = append(offs, bits/unit*bf.Size())
+			} else {
+				offs = append(offs, bits/8)
+			}
+			bits += n
+			continue
+		}
+		off := alignUp(alignUp(bits, 8)/8, fa)
+		offs = append(offs, off)
+		bits = 8 * (off + f.Type.Size())
+	}
+	size := alignUp(alignUp(bits, 8)/8, align)
+	return offs, size, align
+}
+
+func (t Struct) Size() uintptr {
+	_, size, _ := t.layout()
+	return size
+}
+
+func (t Struct) Align() uintptr {
+	_, _, align := t.layout()
+	return align
+}
+
+// Union is a union. Fields are nil if the union is opaque.
+type Union struct {
+	Name   string
+	Fields []Field
+}
+
+func (t Union) String() string {
+	return printFields('(', t.Name, t.Fields, ')')
+}
+
+func (t Union) Size() uintptr {
+	var size uintptr
+	for _, f := range t.Fields {
+		if sz := f.Type.Size(); sz > size {
+			size = sz
+		}
+	}
+	return alignUp(size, t.Align())
+}
+
+func (t Union) Align() uintptr {
+	var align uintptr = 1
+	for _, f := range t.Fields {
+		if a := f.Type.Align(); a > align {
+			align = a
+		}
+	}
+	return align
+}
+
+func printFields(open byte, name string, fields []Field, close byte) string {
+	var sb strings.Builder
+	sb.WriteByte(open)
+	sb.WriteString(name)
+	if fields != nil {
+		sb.WriteByte('=')
+		for _, f := range fields {
+			if f.Name != "" {
+				sb.WriteString(`"` + f.Name + `"`)
+			}
+			sb.WriteString(f.Type.String())
+		}
+	}
+	sb.WriteByte(close)
+	return sb.String()
+}
+
+// Qualifier is a method type qualifier.
+type Qualifier byte
+
+const (
+	Const  = Qualifier('r')
+	In     = Qualifier('n')
+	InOut  = Qualifier('N')
+	Out    = Qualifier('o')
+	ByCopy = Qualifier('O')
+	ByRef  = Qualifier('R')
+	OneWay = Qualifier('V')
+	Atomic = Qualifier('A')
+)
+
+const qualifiers = "rnNoORVA"
+
+func (q Qualifier) String() string {
+	return string(rune(q))
+}
+
+// Qualified is a type with one or more qualifiers.
+type Qualified struct {
+	Qualifiers []Qualifier
+	Type       Type
+}
+
+func (t Qualified) String() string {
+	var sb strings.Builder
+	for _, q := range t.Qualifiers {
+		sb.WriteByte(byte(q))
+	}
+	sb.WriteString(t.Type.String())
+	return sb.String()
+}
+
+func (t Qualified) Size() uintptr  { return t.Type.Size() }
+func (t Qualified) Align() uintptr { return t.Type.Align() }
+
+// Has checks if the type has a given qualifier.
+func (t Qualified) Has(q Qualifier) bool {
+	for _, q2 := range t.Qualifiers {
+		if q2 == q {
+			return true
+		}
+	}
+	return false
+}
+
+// Unqualified returns the type with qualifiers removed.
+func Unqualified(t Type) Type {
+	if q, ok := t.(Qualified); ok {
+		return q.Type
+	}
+	return t
+}
+
+func alignUp(v, a uintptr) uintptr {
+	if a == 0 {
+		return v
+	}
+	return (v + a - 1) / a * a
+}
@@ -0,0 +1,246 @@
+package encoding
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+var parseCases = []struct {
+	enc   string
+	typ   Type
+	size  uintptr
+	align uintptr
+}{
+	{enc: "c", typ: Char, size: 1, align: 1},
+	{enc: "Q", typ: UnsignedLongLong, size: 8, align: int64Align},
+	{enc: "d", typ: Double, size: 8, align: int64Align},
+	{enc: "v", typ: Void, size: 0, align: 1},
+	{enc: "@", typ: Object{}, size: ptrSize, align: ptrSize},
+	{enc: `@"NSString"`, typ: Object{ClassName: "NSString"}, size: ptrSize, align: ptrSize},
+	{enc: "@?", typ: Block{}, size: ptrSize, align: ptrSize},
+	{enc: "^v", typ: Pointer{Elem: Void}, size: ptrSize, align: ptrSize},
+	{enc: "^?", typ: Pointer{Elem: Unknown}, size: ptrSize, align: ptrSize},
+	{enc: "r*", typ: Qualified{Qualifiers: []Qualifier{Const}, Type: CString}, size: ptrSize, align: ptrSize},
+	{enc: "Vv", typ: Qualified{Qualifiers: []Qualifier{OneWay}, Type: Void}, size: 0, align: 1},
+	{enc: "rn^i", typ: Qualified{Qualifiers: []Qualifier{Const, In}, Type: Pointer{Elem: Int}}, size: ptrSize, align: ptrSize},
+	{enc: "[12^f]", typ: Array{Len: 12, Elem: Pointer{Elem: Float}}, size: 12 * ptrSize, align: ptrSize},
+	{enc: "jf", typ: Complex{Elem: Float}, size: 8, align: 4},
+	{
+		enc:  "{CGPoint=dd}",
+		typ:  Struct{Name: "CGPoint", Fields: []Field{{Type: Double}, {Type: Double}}},
+		size: 16, align: int64Align,
+	},
+	{
+		enc:  "{example=@*i}",
+		typ:  Struct{Name: "example", Fields: []Field{{Type: Object{}}, {Type: CString}, {Type: Int}}},
+		size: 3 * ptrSize, align: ptrSize,
+	},
+	{
+		enc: "{CGRect={CGPoint=dd}{CGSize=dd}}",
+		typ: Struct{Name: "CGRect", Fields: []Field{
+			{Type: Struct{Name: "CGPoint", Fields: []Field{{Type: Double}, {Type: Double}}}},
+			{Type: Struct{Name: "CGSize", Fields: []Field{{Type: Double}, {Type: Double}}}},
+		}},
+		size: 32, align: int64Align,
+	},
+	{
+		enc:  `{_NSRange="location"Q"length"Q}`,
+		typ:  Struct{Name: "_NSRange", Fields: []Field{{Name: "location", Type: UnsignedLongLong}, {Name: "length", Type: UnsignedLongLong}}},
+		size: 16, align: int64Align,
+	},
+	{
+		enc: `{?="obj"@"NSString""x"i"y"@}`,
+		typ: Struct{Name: "?", Fields: []Field{
+			{Name: "obj", Type: Object{ClassName: "NSString"}},
+			{Name: "x", Type: Int},
+			{Name: "y", Type: Object{}},
+		}},
+		size: 3 * ptrSize, align: ptrSize,
+	},
+	{
+		enc: `{?="obj"@"x"i}`,
+		typ: Struct{Name: "?", Fields: []Field{
+			{Name: "obj", Type: Object{}},
+			{Name: "x", Type: Int},
+		}},
+		size: 2 * ptrSize, align: ptrSize,
+	},
+	{enc: "{__CFString=}", typ: Struct{Name: "__CFString", Fields: []Field{}}, size: 0, align: 1},
+	{enc: "^{__CFString}", typ: Pointer{Elem: Struct{Name: "__CFString"}}, size: ptrSize, align: ptrSize},
+	{
+		enc:  "(?=iq)",
+		typ:  Union{Name: "?", Fields: []Field{{Type: Int}, {Type: LongLong}}},
+		size: 8, align: int64Align,
+	},
+	{
+		enc:  "{?=b1b3b30c}",
+		typ:  Struct{Name: "?", Fields: []Field{{Type: BitField{Bits: 1}}, {Type: BitField{Bits: 3}}, {Type: BitField{Bits: 30}}, {Type: Char}}},
+		size: 12, align: 4,
+	},
+	{
+		enc:  "{?=b0I1b1I3}",
+		typ:  Struct{Name: "?", Fields: []Field{{Type: BitField{Pos: 0, Type: UnsignedInt, Bits: 1}}, {Type: BitField{Pos: 1, Type: UnsignedInt, Bits: 3}}}},
+		size: 4, align: 4,
+	},
+	{
+		enc:  "{?=ci}",
+		typ:  Struct{Name: "?", Fields: []Field{{Type: Char}, {Type: Int}}},
+		size: 8, align: 4,
+	},
+}
+
+func TestParse(t *testing.T) {
+	for _, c := range parseCases {
+		typ, err := Parse(c.enc)
+		if err != nil {
+			t.Errorf("%q: %v", c.enc, err)
+			continue
+		}
+		if !reflect.DeepEqual(typ, c.typ) {
+			t.Errorf("%q: expected %#v, got %#v", c.enc, c.typ, typ)
+		}
+		if s := typ.String(); s != c.enc {
+			t.Errorf("%q: printed as %q", c.enc, s)
+		}
+		if sz := typ.Size(); sz != c.size {
+			t.Errorf("%q: expected size %d, got %d", c.enc, c.size, sz)
+		}
+		if a := typ.Align(); a != c.align {
+			t.Errorf("%q: expected align %d, got %d", c.enc, c.align, a)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, enc := range []string{
+		"", "x", "ii", "^", "[i]", "[4i", "{CGPoint=dd", "(?=i", `@"NSString`, "b", "{a{b}=i}",
+	} {
+		if typ, err := Parse(enc); err == nil {
+			t.Errorf("%q: expected error, got %#v", enc, typ)
+		}
+	}
+}
+
+func TestStructOffsets(t *testing.T) {
+	type example struct {
+		a int8
+		b int32
+		c int16
+		d float64
+		e uintptr
+	}
+	var v example
+	exp := []uintptr{
+		unsafe.Offsetof(v.a),
+		unsafe.Offsetof(v.b),
+		unsafe.Offsetof(v.c),
+		unsafe.Offsetof(v.d),
+		unsafe.Offsetof(v.e),
+	}
+	typ := MustParse("{example=cisd^v}").(Struct)
+	if got := typ.Offsets(); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected offsets %v, got %v", exp, got)
+	}
+	if sz := typ.Size(); sz != unsafe.Sizeof(v) {
+		t.Errorf("expected size %d, got %d", unsafe.Sizeof(v), sz)
+	}
+}
+
+func TestBasicAlign(t *testing.T) {
+	for _, c := range []struct {
+		typ   Basic
+		align uintptr
+	}{
+		{Char, unsafe.Alignof(int8(0))},
+		{Short, unsafe.Alignof(int16(0))},
+		{Int, unsafe.Alignof(int32(0))},
+		{LongLong, unsafe.Alignof(int64(0))},
+		{UnsignedLongLong, unsafe.Alignof(uint64(0))},
+		{Float, unsafe.Alignof(float32(0))},
+		{Double, unsafe.Alignof(float64(0))},
+		{Bool, unsafe.Alignof(false)},
+		{CString, unsafe.Alignof(uintptr(0))},
+	} {
+		if a := c.typ.Align(); a != c.align {
+			t.Errorf("%q: expected align %d, got %d", c.typ, c.align, a)
+		}
+	}
+	for i := 0; i < len(basicTypes); i++ {
+		typ := Basic(basicTypes[i])
+		if a := typ.Align(); a == 0 || a&(a-1) != 0 {
+			t.Errorf("%q: alignment is not a power of two: %d", typ, a)
+		} else if sz := typ.Size(); sz != 0 && sz%a != 0 {
+			t.Errorf("%q: size %d is not a multiple of alignment %d", typ, sz, a)
+		}
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	for _, c := range []struct {
+		enc string
+		exp Method
+	}{
+		{
+			enc: "v16@0:8",
+			exp: Method{
+				Return: Void, HasOffsets: true, FrameSize: 16,
+				Args: []Arg{{Type: Object{}, Offset: 0}, {Type: Selector, Offset: 8}},
+			},
+		},
+		{
+			enc: "@@:",
+			exp: Method{
+				Return: Object{},
+				Args:   []Arg{{Type: Object{}}, {Type: Selector}},
+			},
+		},
+		{
+			enc: "{CGRect={CGPoint=dd}{CGSize=dd}}24@0:8r^v16",
+			exp: Method{
+				Return: Struct{Name: "CGRect", Fields: []Field{
+					{Type: Struct{Name: "CGPoint", Fields: []Field{{Type: Double}, {Type: Double}}}},
+					{Type: Struct{Name: "CGSize", Fields: []Field{{Type: Double}, {Type: Double}}}},
+				}},
+				HasOffsets: true, FrameSize: 24,
+				Args: []Arg{
+					{Type: Object{}, Offset: 0},
+					{Type: Selector, Offset: 8},
+					{Type: Qualified{Qualifiers: []Qualifier{Const}, Type: Pointer{Elem: Void}}, Offset: 16},
+				},
+			},
+		},
+		{
+			enc: "c24@0:8@16",
+			exp: Method{
+				Return: Char, HasOffsets: true, FrameSize: 24,
+				Args: []Arg{{Type: Object{}, Offset: 0}, {Type: Selector, Offset: 8}, {Type: Object{}, Offset: 16}},
+			},
+		},
+	} {
+		m, err := ParseMethod(c.enc)
+		if err != nil {
+			t.Errorf("%q: %v", c.enc, err)
+			continue
+		}
+		if !reflect.DeepEqual(*m, c.exp) {
+			t.Errorf("%q: expected %#v, got %#v", c.enc, c.exp, *m)
+		}
+		if s := m.String(); s != c.enc {
+			t.Errorf("%q: printed as %q", c.enc, s)
+		}
+	}
+
+	// GNU runtime may use signed offsets
+	m, err := ParseMethod("v20@+0:+8i+16")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(m.Args) != 3 || m.Args[2].Offset != 16 || m.Args[2].Type != Int {
+		t.Errorf("unexpected result: %#v", m)
+	}
+	for _, enc := range []string{"", "v16@0:", "v16@0:8%", "{a=i"} {
+		if _, err := ParseMethod(enc); err == nil {
+			t.Errorf("%q: expected error", enc)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package encoding
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses a single type encoding.
+func Parse(s string) (Type, error) {
+	p := &parser{s: s}
+	t, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	if p.i != len(s) {
+		return nil, p.errorf("unexpected trailing data")
+	}
+	return t, nil
+}
+
+// MustParse is like Parse, but panics on error.
+func MustParse(s string) Type {
+	t, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Arg is an argument of a method.
+type Arg struct {
+	Type   Type
+	Offset int // offset of the argument in the frame; only valid if Method.HasOffsets is set
+}
+
+// Method is a parsed method type encoding.
+type Method struct {
+	Return Type
+	Args   []Arg // including the receiver and the selector
+
+	HasOffsets bool
+	FrameSize  int // size of arguments frame; only valid if HasOffsets is set
+}
+
+func (m *Method) String() string {
+	var sb strings.Builder
+	sb.WriteString(m.Return.String())
+	if m.HasOffsets {
+		sb.WriteString(strconv.Itoa(m.FrameSize))
+	}
+	for _, a := range m.Args {
+		sb.WriteString(a.Type.String())
+		if m.HasOffsets {
+			sb.WriteString(strconv.Itoa(a.Offset))
+		}
+	}
+	return sb.String()
+}
+
+// ParseMethod parses a method type encoding, as returned by method_getTypeEncoding.
+func ParseMethod(s string) (*Method, error) {
+	p := &parser{s: s}
+	ret, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	m := &Method{Return: ret}
+	m.FrameSize, m.HasOffsets = p.parseOffset()
+	for p.i < len(s) {
+		t, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		off, ok := p.parseOffset()
+		if ok != m.HasOffsets {
+			return nil, p.errorf("expected argument offset")
+		}
+		m.Args = append(m.Args, Arg{Type: t, Offset: off})
+	}
+	return m, nil
+}
+
+type parser struct {
+	s string
+	i int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid type encoding %q at %d: %s", p.s, p.i, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) eof() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parseNumber parses an unsigned decimal number.
+func (p *parser) parseNumber() (int, bool) {
+	start := p.i
+	for !p.eof() && isDigit(p.s[p.i]) {
+		p.i++
+	}
+	if start == p.i {
+		return 0, false
+	}
+	v, err := strconv.Atoi(p.s[start:p.i])
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// parseOffset parses an optional argument offset. GNU runtime may prefix it with a sign.
+func (p *parser) parseOffset() (int, bool) {
+	start := p.i
+	neg := false
+	switch p.peek() {
+	case '+':
+		p.i++
+	case '-':
+		neg = true
+		p.i++
+	}
+	v, ok := p.parseNumber()
+	if !ok {
+		p.i = start
+		return 0, false
+	}
+	if neg {
+		v = -v
+	}
+	return v, true
+}
+
+// parseQuoted parses a quoted string.
+func (p *parser) parseQuoted() (string, error) {
+	if p.peek() != '"' {
+		return "", p.errorf("expected '\"'")
+	}
+	j := strings.IndexByte(p.s[p.i+1:], '"')
+	if j < 0 {
+		return "", p.errorf("unterminated string")
+	}
+	v := p.s[p.i+1 : p.i+1+j]
+	p.i += j + 2
+	return v, nil
+}
+
+func (p *parser) parseType() (Type, error) {
+	return p.parseTypeIn(false)
+}
+
+// parseTypeIn parses a type. The inFields flag is set when parsing fields of a structure
+// or union that have names, which affects how quoted strings after '@' are interpreted.
+func (p *parser) parseTypeIn(inFields bool) (Type, error) {
+	if p.eof() {
+		return nil, p.errorf("unexpected end of type")
+	}
+	var qual []Qualifier
+	for !p.eof() && strings.IndexByte(qualifiers, p.s[p.i]) >= 0 {
+		qual = append(qual, Qualifier(p.s[p.i]))
+		p.i++
+	}
+	t, err := p.parseUnqualified(inFields)
+	if err != nil {
+		return nil, err
+	}
+	if len(qual) != 0 {
+		return Qualified{Qualifiers: qual, Type: t}, nil
+	}
+	return t, nil
+}
+
+func (p *parser) parseUnqualified(inFields bool) (Type, error) {
+	if p.eof() {
+		return nil, p.errorf("unexpected end of type")
+	}
+	c := p.s[p.i]
+	switch c {
+	case '^':
+		p.i++
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return Pointer{Elem: elem}, nil
+	case 'j':
+		p.i++
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return Complex{Elem: elem}, nil
+	case '@':
+		p.i++
+		switch p.peek() {
+		case '?':
+			p.i++
+			return Block{}, nil
+		case '"':
+			start := p.i
+			name, err := p.parseQuoted()
+			if err != nil {
+				return nil, err
+			}
+			// Inside structures with named fields, a quoted string after '@' may be either
+			// a class name or a name of the next field. It's a class name only if it's
+			// followed by another field name or by the end of the structure.
+			if inFields {
+				switch p.peek() {
+				case '"', '}', ')', 0:
+				default:
+					p.i = start
+					return Object{}, nil
+				}
+			}
+			return Object{ClassName: name}, nil
+		}
+		return Object{}, nil
+	case 'b':
+		p.i++
+		n, ok := p.parseNumber()
+		if !ok {
+			return nil, p.errorf("expected bit field size")
+		}
+		// GNU runtime encodes position, type and size; Apple runtime encodes only the size,
+		// so a type character is a part of the bit field only if it's followed by a number
+		if p.i+1 < len(p.s) && strings.IndexByte("cislqCISLQB", p.s[p.i]) >= 0 && isDigit(p.s[p.i+1]) {
+			typ := Basic(p.s[p.i])
+			p.i++
+			bits, ok := p.parseNumber()
+			if !ok {
+				return nil, p.errorf("expected bit field size")
+			}
+			return BitField{Pos: n, Type: typ, Bits: bits}, nil
+		}
+		return BitField{Bits: n}, nil
+	case '[':
+		p.i++
+		n, ok := p.parseNumber()
+		if !ok {
+			return nil, p.errorf("expected array length")
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ']' {
+			return nil, p.errorf("expected ']'")
+		}
+		p.i++
+		return Array{Len: n, Elem: elem}, nil
+	case '{', '(':
+		end := byte('}')
+		if c == '(' {
+			end = ')'
+		}
+		p.i++
+		name, fields, err := p.parseFields(end)
+		if err != nil {
+			return nil, err
+		}
+		if c == '(' {
+			return Union{Name: name, Fields: fields}, nil
+		}
+		return Struct{Name: name, Fields: fields}, nil
+	}
+	if strings.IndexByte(basicTypes, c) >= 0 {
+		p.i++
+		return Basic(c), nil
+	}
+	return nil, p.errorf("unknown type %q", c)
+}
+
+// parseFields parses the name and fields of a structure or union, including the closing bracket.
+func (p *parser) parseFields(end byte) (string, []Field, error) {
+	start := p.i
+	for !p.eof() {
+		switch p.s[p.i] {
+		case '=':
+			name := p.s[start:p.i]
+			p.i++
+			fields := []Field{}
+			for {
+				if p.eof() {
+					return "", nil, p.errorf("expected %q", end)
+				} else if p.s[p.i] == end {
+					p.i++
+					return name, fields, nil
+				}
+				var (
+					f     Field
+					named bool
+				)
+				if p.peek() == '"' {
+					var err error
+					f.Name, err = p.parseQuoted()
+					if err != nil {
+						return "", nil, err
+					}
+					named = true
+				}
+				t, err := p.parseTypeIn(named)
+				if err != nil {
+					return "", nil, err
+				}
+				f.Type = t
+				fields = append(fields, f)
+			}
+		case end:
+			name := p.s[start:p.i]
+			p.i++
+			return name, nil, nil
+		case '{', '(', '[', '"', '}', ')':
+			return "", nil, p.errorf("unexpected %q in type name", p.s[p.i])
+		}
+		p.i++
+	}
+	return "", nil, p.errorf("expected %q", end)
+}
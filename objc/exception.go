@@ -0,0 +1,113 @@
+package objc
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Exception is an Objective-C exception raised by a method and converted to a Go error.
+//
+// Exceptions are caught when the package is compiled with Clang. GCC requires additional flags,
+// see exception.m for details. Runtime reports CapExceptions if exceptions are caught,
+// otherwise they unwind through Go frames, which is undefined behavior.
+//
+// The exception object is retained until the Exception is garbage collected.
+type Exception struct {
+	Name     string // name of the exception, or the class name if it's not an NSException
+	Reason   string // reason of the exception, if any
+	UserInfo Object // user info dictionary, if any; valid while the Exception is reachable
+
+	ref *Ref
+}
+
+// Object returns the exception object.
+func (e *Exception) Object() Object {
+	return e.ref.Object()
+}
+
+func (e *Exception) Error() string {
+	if e.Reason == "" {
+		return "objc: exception: " + e.Name
+	}
+	return "objc: exception: " + e.Name + ": " + e.Reason
+}
+
+// newException creates an Exception from an exception object.
+func newException(o Object) *Exception {
+	e := &Exception{ref: Retain(o)}
+	if cl := o.Class(); cl != nil {
+		e.Name = cl.Name()
+	}
+	if s, ok := o.sendString("name"); ok {
+		e.Name = s
+	}
+	e.Reason, _ = o.sendString("reason")
+	if v, ok := o.sendObject("userInfo"); ok {
+		e.UserInfo = v
+	}
+	return e
+}
+
+// sendObject sends a message that returns an object, if the object implements it.
+func (o Object) sendObject(name string) (Object, bool) {
+	sel := RegisterSelector(name)
+	if class_getInstanceMethod(object_getClass(o.id), sel.sel) == nil {
+		return Object{}, false
+	}
+	res, err := o.Send(sel)
+	if err != nil {
+		return Object{}, false
+	}
+	v, ok := res.(Object)
+	return v, ok
+}
+
+// sendString sends a message that returns a string object, if the object implements it.
+func (o Object) sendString(name string) (string, bool) {
+	s, ok := o.sendObject(name)
+	if !ok || s.IsNil() {
+		return "", false
+	}
+	sel := RegisterSelector("UTF8String")
+	if class_getInstanceMethod(object_getClass(s.id), sel.sel) == nil {
+		return "", false
+	}
+	res, err := s.Send(sel)
+	if err != nil {
+		return "", false
+	}
+	p, ok := res.(unsafe.Pointer)
+	if !ok || p == nil {
+		return "", false
+	}
+	return goString(p), true
+}
+
+// TrySend sends a message to the object like Send, but returns Objective-C exceptions separately
+// from other errors, such as argument conversion errors.
+//
+// It returns an error without sending the message if exceptions are not caught by the package,
+// see CapExceptions.
+func (o Object) TrySend(sel Selector, args ...interface{}) (interface{}, *Exception, error) {
+	if !Runtime().Has(CapExceptions) {
+		return nil, nil, fmt.Errorf("objc: exceptions are not caught, see CapExceptions")
+	}
+	res, err := o.Send(sel, args...)
+	if e, ok := err.(*Exception); ok {
+		return nil, e, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return res, nil, nil
+}
+
+// MustSend sends a message to the object like Send, but panics in case of an error.
+// Objective-C exceptions are raised as *Exception panics that can be recovered,
+// if the runtime reports CapExceptions.
+func (o Object) MustSend(sel Selector, args ...interface{}) interface{} {
+	res, err := o.Send(sel, args...)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
@@ -0,0 +1,48 @@
+//go:build !objcfake
+// +build !objcfake
+
+#include <stdint.h>
+#include <objc/runtime.h>
+#include "call.h"
+#include "_cgo_export.h"
+
+// Wrappers for go_call_* functions that convert Objective-C exceptions to Go values.
+// Exceptions must not unwind through Go frames, thus they are caught here.
+//
+// GCC requires -fobjc-exceptions for the exception syntax, which is not allowed in #cgo directives.
+// Thus, with GCC exceptions are only caught if the package is built with:
+//
+//	CGO_CFLAGS="-fobjc-exceptions -DGO_OBJC_EXCEPTIONS"
+//
+// Otherwise, exceptions are not caught and CapExceptions is not reported, see GO_OBJC_CATCHES.
+
+#if GO_OBJC_CATCHES
+
+#define GO_TRY_CALL(call) \
+	*exc = 0; \
+	@try { \
+		return call; \
+	} @catch (id e) { \
+		*exc = goObjcException(e); \
+	} \
+	return 0;
+
+#else
+
+#define GO_TRY_CALL(call) \
+	*exc = 0; \
+	return call;
+
+#endif
+
+uintptr_t go_try_call_int(void *imp, void *self, void *sel, uintptr_t *a, double *f, uintptr_t *exc) {
+	GO_TRY_CALL(go_call_int(imp, self, sel, a, f))
+}
+
+float go_try_call_float(void *imp, void *self, void *sel, uintptr_t *a, double *f, uintptr_t *exc) {
+	GO_TRY_CALL(go_call_float(imp, self, sel, a, f))
+}
+
+double go_try_call_double(void *imp, void *self, void *sel, uintptr_t *a, double *f, uintptr_t *exc) {
+	GO_TRY_CALL(go_call_double(imp, self, sel, a, f))
+}
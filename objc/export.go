@@ -0,0 +1,54 @@
+//go:build !objcfake
+// +build !objcfake
+
+package objc
+
+/*
+#include <stdint.h>
+
+void *go_imp_trampoline(int depth, int kind);
+*/
+import "C"
+
+import (
+	"runtime/cgo"
+	"unsafe"
+)
+
+// impTrampoline returns a trampoline that should be used as an IMP for Go methods with a given
+// result type, added to a class at a given depth in the hierarchy. It returns nil if the depth is too large.
+func impTrampoline(ret string, depth int) unsafe.Pointer {
+	kind := 0
+	switch ret {
+	case "f":
+		kind = 1
+	case "d":
+		kind = 2
+	}
+	return C.go_imp_trampoline(C.int(depth), C.int(kind))
+}
+
+//export goObjcImp
+func goObjcImp(depth C.int, self, cmd unsafe.Pointer, ints *C.uintptr_t, floats *C.double, out *C.uint64_t) {
+	defer recoverCallback(cmd, (*uint64)(unsafe.Pointer(out)))
+	*out = C.uint64_t(callGoImp(int(depth), self, cmd,
+		(*[maxIntArgs]uint64)(unsafe.Pointer(ints)),
+		(*[maxFloatArgs]float64)(unsafe.Pointer(floats)),
+	))
+}
+
+//export goObjcException
+func goObjcException(e unsafe.Pointer) C.uintptr_t {
+	return C.uintptr_t(cgo.NewHandle(newException(Object{id: cID(e)})))
+}
+
+// takeException returns an exception stored in a handle by goObjcException.
+func takeException(h uintptr) error {
+	if h == 0 {
+		return nil
+	}
+	ch := cgo.Handle(h)
+	e := ch.Value().(*Exception)
+	ch.Delete()
+	return e
+}
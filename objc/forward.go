@@ -0,0 +1,461 @@
+package objc
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/dennwc/go-apple/objc/encoding"
+)
+
+// SetResolveInstanceMethod installs a handler for +resolveInstanceMethod: of the class.
+//
+// The runtime calls the handler when an instance of the class or its subclass receives
+// a message it doesn't implement, passing the class of the receiver. The handler may add
+// the method with Class.AddMethod and return true, in which case the message is sent again.
+// Otherwise, the message is forwarded, see SetForwardingTarget and SetForwardInvocation.
+//
+// The handler must not send the selector it resolves to instances of the class.
+func (b *ClassBuilder) SetResolveInstanceMethod(fnc func(c *Class, sel Selector) bool) error {
+	if fnc == nil {
+		return fmt.Errorf("objc: nil resolve handler for class %s", b.class.Name())
+	}
+	return b.AddClassMethod(RegisterSelector("resolveInstanceMethod:"), func(self Object, cmd Selector, sel Selector) bool {
+		return fnc(&Class{class: cClass(self.Pointer())}, sel)
+	})
+}
+
+// SetForwardingTarget installs a handler for -forwardingTargetForSelector: of the class.
+//
+// The runtime calls the handler when an instance of the class receives a message that it
+// doesn't implement and that was not resolved by +resolveInstanceMethod:. The message is
+// sent to the returned object instead. If the handler returns nil or the receiver itself,
+// the message is passed to forwardInvocation:, see SetForwardInvocation.
+//
+// Forwarding is only supported by some runtimes, see CapForwarding.
+func (b *ClassBuilder) SetForwardingTarget(fnc func(self Object, sel Selector) Object) error {
+	if fnc == nil {
+		return fmt.Errorf("objc: nil forwarding handler for class %s", b.class.Name())
+	}
+	c := b.class.class
+	err := addMethod(c, RegisterSelector("forwardingTargetForSelector:"), func(self Object, cmd Selector, sel Selector) Object {
+		if t := fnc(self, sel); !t.IsNil() {
+			return t
+		}
+		res, _ := sendSuper(c, self, cmd, sel)
+		t, _ := res.(Object)
+		return t
+	})
+	if err != nil {
+		return err
+	}
+	setForwarder(c, func(fw *forwarder) {
+		fw.target = fnc
+	})
+	return addMethodSignature(c)
+}
+
+// SetForwardInvocation installs handlers for -methodSignatureForSelector: and -forwardInvocation: of the class.
+//
+// The runtime calls them when an instance of the class receives a message that was neither
+// resolved nor redirected by SetForwardingTarget. The signature handler returns the type encoding
+// of the method, or an empty string if the object doesn't respond to the selector. The invocation
+// handler then receives the message with its arguments. It may inspect and change them, invoke
+// the message on another object and set the return value.
+//
+// Invocations require NSInvocation and NSMethodSignature classes from Foundation, see CapForwarding.
+func (b *ClassBuilder) SetForwardInvocation(sig func(self Object, sel Selector) string, fnc func(self Object, inv Invocation)) error {
+	if sig == nil || fnc == nil {
+		return fmt.Errorf("objc: nil invocation handler for class %s", b.class.Name())
+	}
+	c := b.class.class
+	err := addMethod(c, RegisterSelector("forwardInvocation:"), func(self Object, cmd Selector, inv Object) {
+		fnc(self, Invocation{Object: inv})
+	})
+	if err != nil {
+		return err
+	}
+	setForwarder(c, func(fw *forwarder) {
+		fw.signature = sig
+	})
+	return addMethodSignature(c)
+}
+
+// forwarder holds forwarding handlers of a class. They are used to report signatures of forwarded methods.
+type forwarder struct {
+	target    func(self Object, sel Selector) Object
+	signature func(self Object, sel Selector) string
+}
+
+var forwarders = struct {
+	sync.RWMutex
+	byClass map[cClass]forwarder
+}{
+	byClass: make(map[cClass]forwarder),
+}
+
+func setForwarder(c cClass, fnc func(fw *forwarder)) {
+	forwarders.Lock()
+	defer forwarders.Unlock()
+	fw := forwarders.byClass[c]
+	fnc(&fw)
+	forwarders.byClass[c] = fw
+}
+
+func getForwarder(c cClass) forwarder {
+	forwarders.RLock()
+	defer forwarders.RUnlock()
+	return forwarders.byClass[c]
+}
+
+func removeForwarder(c cClass) {
+	forwarders.Lock()
+	delete(forwarders.byClass, c)
+	forwarders.Unlock()
+}
+
+// types returns the type encoding of a method forwarded by the object.
+func (fw forwarder) types(self Object, sel Selector) string {
+	if fw.signature != nil {
+		if types := fw.signature(self, sel); types != "" {
+			return types
+		}
+	}
+	if fw.target != nil {
+		if t := fw.target(self, sel); !t.IsNil() && t != self {
+			return t.methodTypes(sel)
+		}
+	}
+	return ""
+}
+
+// addMethodSignature adds -methodSignatureForSelector: that reports signatures of methods
+// forwarded by the class. It's shared by all forwarding handlers of the class.
+func addMethodSignature(c cClass) error {
+	sel := RegisterSelector("methodSignatureForSelector:")
+	if getGoMethod(c, sel.Name()) != nil {
+		return nil
+	}
+	return addMethod(c, sel, func(self Object, cmd Selector, s Selector) Object {
+		if types := getForwarder(c).types(self, s); types != "" {
+			if sig, err := methodSignature(types); err == nil {
+				return sig
+			}
+		}
+		res, _ := sendSuper(c, self, cmd, s)
+		sig, _ := res.(Object)
+		return sig
+	})
+}
+
+// sendSuper sends a message to the object using the implementation from the superclass of a given class.
+// It returns nil if the superclass doesn't implement the method. Go methods of the superclass are
+// dispatched by the class they were added to, thus handlers of the subclass are not called again.
+func sendSuper(c cClass, self Object, sel Selector, args ...interface{}) (interface{}, error) {
+	super := class_getSuperclass(c)
+	if super == nil || class_getInstanceMethod(super, sel.sel) == nil {
+		return nil, nil
+	}
+	return self.send(class_getMethodImplementation(super, sel.sel), sel, args)
+}
+
+// forwardedTypes returns the type encoding of a method forwarded by the object,
+// as reported by its methodSignatureForSelector:.
+func (o Object) forwardedTypes(sel Selector) string {
+	msel := RegisterSelector("methodSignatureForSelector:")
+	if sel.Equal(msel) || class_getInstanceMethod(object_getClass(o.id), msel.sel) == nil {
+		return ""
+	}
+	res, err := o.Send(msel, sel)
+	if err != nil {
+		return ""
+	}
+	sig, _ := res.(Object)
+	if sig.IsNil() {
+		return ""
+	}
+	types, err := signatureTypes(sig)
+	if err != nil {
+		return ""
+	}
+	return types
+}
+
+// typeStrings holds C strings of type encodings passed to NSMethodSignature.
+// They are never freed, since signatures may keep references to them.
+var typeStrings = struct {
+	sync.Mutex
+	byTypes map[string]unsafe.Pointer
+}{
+	byTypes: make(map[string]unsafe.Pointer),
+}
+
+// cTypeString returns a C string of the type encoding.
+func cTypeString(types string) unsafe.Pointer {
+	typeStrings.Lock()
+	defer typeStrings.Unlock()
+	if p, ok := typeStrings.byTypes[types]; ok {
+		return p
+	}
+	n := len(types)
+	p := malloc(uintptr(n) + 1)
+	buf := (*[1 << 28]byte)(p)[: n+1 : n+1]
+	copy(buf, types)
+	buf[n] = 0
+	typeStrings.byTypes[types] = p
+	return p
+}
+
+// methodSignature creates an NSMethodSignature for the type encoding.
+func methodSignature(types string) (Object, error) {
+	c := GetClass("NSMethodSignature")
+	if c == nil {
+		return Object{}, fmt.Errorf("objc: NSMethodSignature class not found")
+	}
+	res, err := c.Send(RegisterSelector("signatureWithObjCTypes:"), cTypeString(types))
+	if err != nil {
+		return Object{}, err
+	}
+	sig, _ := res.(Object)
+	if sig.IsNil() {
+		return Object{}, fmt.Errorf("objc: invalid method signature: %q", types)
+	}
+	return sig, nil
+}
+
+// sendCString sends a message that returns a C string.
+func (o Object) sendCString(sel Selector, args ...interface{}) (string, error) {
+	res, err := o.Send(sel, args...)
+	if err != nil {
+		return "", err
+	}
+	p, ok := res.(unsafe.Pointer)
+	if !ok || p == nil {
+		return "", fmt.Errorf("objc: %q: expected a C string, got %v", sel.Name(), res)
+	}
+	return goString(p), nil
+}
+
+// signatureTypes returns the type encoding of an NSMethodSignature.
+func signatureTypes(sig Object) (string, error) {
+	types, err := sig.sendCString(RegisterSelector("methodReturnType"))
+	if err != nil {
+		return "", err
+	}
+	res, err := sig.Send(RegisterSelector("numberOfArguments"))
+	if err != nil {
+		return "", err
+	}
+	n, err := uintValue(res, encoding.UnsignedLongLong)
+	if err != nil {
+		return "", err
+	}
+	sel := RegisterSelector("getArgumentTypeAtIndex:")
+	for i := uint64(0); i < n; i++ {
+		arg, err := sig.sendCString(sel, i)
+		if err != nil {
+			return "", err
+		}
+		types += arg
+	}
+	return types, nil
+}
+
+// Invocation is a message captured for forwarding, an instance of NSInvocation.
+// See ClassBuilder.SetForwardInvocation.
+//
+// Arguments are indexed from zero, excluding the receiver and the selector, same as for Object.Send.
+// Values are converted as described in Object.Send and Object.GetIvar.
+type Invocation struct {
+	Object
+}
+
+// Selector returns the selector of the message.
+func (inv Invocation) Selector() Selector {
+	res, err := inv.Send(RegisterSelector("selector"))
+	if err != nil {
+		return Selector{}
+	}
+	sel, _ := res.(Selector)
+	return sel
+}
+
+// SetSelector changes the selector of the message.
+func (inv Invocation) SetSelector(sel Selector) error {
+	_, err := inv.Send(RegisterSelector("setSelector:"), sel)
+	return err
+}
+
+// Target returns the receiver of the message.
+func (inv Invocation) Target() Object {
+	res, err := inv.Send(RegisterSelector("target"))
+	if err != nil {
+		return Object{}
+	}
+	t, _ := res.(Object)
+	return t
+}
+
+// SetTarget changes the receiver of the message.
+func (inv Invocation) SetTarget(o Object) error {
+	_, err := inv.Send(RegisterSelector("setTarget:"), o)
+	return err
+}
+
+// Signature returns the signature of the message, including the receiver and the selector.
+func (inv Invocation) Signature() (*encoding.Method, error) {
+	types, err := inv.types()
+	if err != nil {
+		return nil, err
+	}
+	return encoding.ParseMethod(types)
+}
+
+func (inv Invocation) types() (string, error) {
+	res, err := inv.Send(RegisterSelector("methodSignature"))
+	if err != nil {
+		return "", err
+	}
+	sig, _ := res.(Object)
+	if sig.IsNil() {
+		return "", fmt.Errorf("objc: invocation has no method signature")
+	}
+	return signatureTypes(sig)
+}
+
+// argType returns the type encoding of an argument.
+func (inv Invocation) argType(i int) (string, error) {
+	sig, err := inv.Signature()
+	if err != nil {
+		return "", err
+	}
+	// skip self and _cmd
+	if i < 0 || i+2 >= len(sig.Args) {
+		return "", fmt.Errorf("objc: argument %d out of range [0, %d)", i, len(sig.Args)-2)
+	}
+	return sig.Args[i+2].Type.String(), nil
+}
+
+// NumArguments returns the number of arguments of the message, excluding the receiver and the selector.
+func (inv Invocation) NumArguments() int {
+	sig, err := inv.Signature()
+	if err != nil || len(sig.Args) < 2 {
+		return 0
+	}
+	return len(sig.Args) - 2
+}
+
+// valueBuffer allocates memory for a value of the type encoding. It must be freed by the caller.
+func valueBuffer(enc string) (unsafe.Pointer, error) {
+	t, err := encoding.Parse(enc)
+	if err != nil {
+		return nil, err
+	}
+	size := t.Size()
+	if size < 8 {
+		// values are converted through registers
+		size = 8
+	}
+	p := malloc(size)
+	buf := (*[1 << 28]byte)(p)[:size:size]
+	for i := range buf {
+		buf[i] = 0
+	}
+	return p, nil
+}
+
+// Argument returns the value of the argument.
+func (inv Invocation) Argument(i int) (interface{}, error) {
+	enc, err := inv.argType(i)
+	if err != nil {
+		return nil, err
+	}
+	p, err := valueBuffer(enc)
+	if err != nil {
+		return nil, err
+	}
+	defer free(p)
+	if _, err = inv.Send(RegisterSelector("getArgument:atIndex:"), p, i+2); err != nil {
+		return nil, err
+	}
+	return readValue(p, enc)
+}
+
+// SetArgument changes the value of the argument.
+func (inv Invocation) SetArgument(i int, val interface{}) error {
+	enc, err := inv.argType(i)
+	if err != nil {
+		return err
+	}
+	p, err := valueBuffer(enc)
+	if err != nil {
+		return err
+	}
+	defer free(p)
+	if err = writeValue(p, enc, objectValue(val)); err != nil {
+		return fmt.Errorf("objc: argument %d: %v", i, err)
+	}
+	_, err = inv.Send(RegisterSelector("setArgument:atIndex:"), p, i+2)
+	return err
+}
+
+// returnType returns the type encoding of the result, or an empty string for void methods.
+func (inv Invocation) returnType() (string, error) {
+	sig, err := inv.Signature()
+	if err != nil {
+		return "", err
+	}
+	if encoding.Unqualified(sig.Return) == encoding.Void {
+		return "", nil
+	}
+	return sig.Return.String(), nil
+}
+
+// ReturnValue returns the result of the message. It returns nil for methods returning void.
+func (inv Invocation) ReturnValue() (interface{}, error) {
+	enc, err := inv.returnType()
+	if err != nil || enc == "" {
+		return nil, err
+	}
+	p, err := valueBuffer(enc)
+	if err != nil {
+		return nil, err
+	}
+	defer free(p)
+	if _, err = inv.Send(RegisterSelector("getReturnValue:"), p); err != nil {
+		return nil, err
+	}
+	return readValue(p, enc)
+}
+
+// SetReturnValue sets the result of the message.
+func (inv Invocation) SetReturnValue(val interface{}) error {
+	enc, err := inv.returnType()
+	if err != nil {
+		return err
+	} else if enc == "" {
+		return fmt.Errorf("objc: set return value of void method")
+	}
+	p, err := valueBuffer(enc)
+	if err != nil {
+		return err
+	}
+	defer free(p)
+	if err = writeValue(p, enc, objectValue(val)); err != nil {
+		return fmt.Errorf("objc: return value: %v", err)
+	}
+	_, err = inv.Send(RegisterSelector("setReturnValue:"), p)
+	return err
+}
+
+// Invoke sends the message to its target and stores the result.
+func (inv Invocation) Invoke() error {
+	_, err := inv.Send(RegisterSelector("invoke"))
+	return err
+}
+
+// InvokeWithTarget sends the message to a given object and stores the result.
+func (inv Invocation) InvokeWithTarget(o Object) error {
+	_, err := inv.Send(RegisterSelector("invokeWithTarget:"), o)
+	return err
+}
@@ -0,0 +1,415 @@
+//go:build objcfake
+// +build objcfake
+
+package objc
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/dennwc/go-apple/objc/encoding"
+)
+
+// Message forwarding in the fake runtime follows the Apple runtime. A message that the receiver
+// doesn't implement is passed to +resolveInstanceMethod:, then to -forwardingTargetForSelector:
+// and finally to -forwardInvocation: with an NSInvocation built from the argument registers.
+// Only arguments that fit in a register are supported.
+
+// fakeForwardIMP is returned by msgLookup for messages that must be forwarded.
+var fakeForwardIMP = &fakeIMP{forward: true}
+
+type fakeResolveKey struct {
+	class  cClass
+	sel    cSEL
+	thread uintptr
+}
+
+// fakeResolving holds methods that are being resolved. It prevents recursion
+// when the resolver looks up the method it resolves.
+var fakeResolving = struct {
+	sync.Mutex
+	keys map[fakeResolveKey]struct{}
+}{
+	keys: make(map[fakeResolveKey]struct{}),
+}
+
+// fakeArgs returns arguments with given pointer values.
+func fakeArgs(ptrs ...unsafe.Pointer) *callArgs {
+	var a callArgs
+	for _, p := range ptrs {
+		*(*unsafe.Pointer)(unsafe.Pointer(&a.ints[a.ni])) = p
+		a.ni++
+	}
+	return &a
+}
+
+// fakePointer converts a result register value to a pointer.
+func fakePointer(w uint64) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&w))
+}
+
+// fakeResolve sends +resolveInstanceMethod: to the class and reports if the method was added.
+// Class methods are not resolved.
+func fakeResolve(c cClass, sel cSEL) bool {
+	if c == nil || sel == nil || c.meta {
+		return false
+	}
+	rsel := sel_registerName("resolveInstanceMethod:")
+	imp := class_getMethodImplementation(c.isa, rsel)
+	if imp == nil {
+		return false
+	}
+	// keep the goroutine on the same thread, so nested resolutions are detected
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	key := fakeResolveKey{class: c, sel: sel, thread: threadID()}
+	fakeResolving.Lock()
+	_, busy := fakeResolving.keys[key]
+	if !busy {
+		fakeResolving.keys[key] = struct{}{}
+	}
+	fakeResolving.Unlock()
+	if busy {
+		return false
+	}
+	defer func() {
+		fakeResolving.Lock()
+		delete(fakeResolving.keys, key)
+		fakeResolving.Unlock()
+	}()
+	w, err := callInt(imp, unsafe.Pointer(c), unsafe.Pointer(rsel), fakeArgs(unsafe.Pointer(sel)))
+	return err == nil && uint8(w) != 0
+}
+
+// fakeForwards checks if instances of the class forward messages.
+func fakeForwards(c cClass) bool {
+	return fakeLookup(c, sel_registerName("forwardingTargetForSelector:")) != nil ||
+		fakeLookup(c, sel_registerName("forwardInvocation:")) != nil
+}
+
+func fakeUnrecognized(obj cID, sel cSEL) error {
+	return fmt.Errorf("objc: %s does not respond to %q", object_getClassName(obj), sel_getName(sel))
+}
+
+// fakeForward forwards a message that the receiver doesn't implement.
+func fakeForward(self, sel unsafe.Pointer, a *callArgs) (uint64, error) {
+	obj, s := cID(self), cSEL(sel)
+	c := object_getClass(obj)
+	tsel := sel_registerName("forwardingTargetForSelector:")
+	if imp := class_getMethodImplementation(c, tsel); imp != nil {
+		w, err := callInt(imp, self, unsafe.Pointer(tsel), fakeArgs(sel))
+		if err != nil {
+			return 0, err
+		}
+		if t := fakePointer(w); t != nil && t != self {
+			imp := msgLookup(cID(t), s)
+			if imp == nil {
+				return 0, fakeUnrecognized(cID(t), s)
+			}
+			return fakeCall(imp, t, sel, a)
+		}
+	}
+	msel := sel_registerName("methodSignatureForSelector:")
+	fsel := sel_registerName("forwardInvocation:")
+	mimp := class_getMethodImplementation(c, msel)
+	fimp := class_getMethodImplementation(c, fsel)
+	if mimp == nil || fimp == nil {
+		return 0, fakeUnrecognized(obj, s)
+	}
+	w, err := callInt(mimp, self, unsafe.Pointer(msel), fakeArgs(sel))
+	if err != nil {
+		return 0, err
+	}
+	sig := cID(fakePointer(w))
+	if sig == nil {
+		return 0, fakeUnrecognized(obj, s)
+	}
+	inv, err := newFakeInvocation(sig, obj, s, a)
+	if err != nil {
+		return 0, err
+	}
+	defer fakeRelease(inv.obj)
+	if _, err = callInt(fimp, self, unsafe.Pointer(fsel), fakeArgs(unsafe.Pointer(inv.obj))); err != nil {
+		return 0, err
+	}
+	return inv.ret, nil
+}
+
+// fakeSignature is a state of an NSMethodSignature object.
+type fakeSignature struct {
+	ret  string
+	args []string // including the receiver and the selector
+}
+
+// fakeInvocation is a state of an NSInvocation object.
+type fakeInvocation struct {
+	obj    cID
+	sig    cID // retained NSMethodSignature
+	target cID
+	sel    cSEL
+	args   []uint64 // register values of arguments, excluding the receiver and the selector
+	ret    uint64   // register value of the result
+}
+
+// fakeForwarding holds states of method signatures and invocations.
+var fakeForwarding = struct {
+	sync.Mutex
+	sigs map[cID]*fakeSignature
+	invs map[cID]*fakeInvocation
+}{
+	sigs: make(map[cID]*fakeSignature),
+	invs: make(map[cID]*fakeInvocation),
+}
+
+func fakeException(format string, args ...interface{}) *Exception {
+	return &Exception{Name: "NSInvalidArgumentException", Reason: fmt.Sprintf(format, args...)}
+}
+
+func getFakeSignature(obj cID) *fakeSignature {
+	fakeForwarding.Lock()
+	sig := fakeForwarding.sigs[obj]
+	fakeForwarding.Unlock()
+	if sig == nil {
+		panic(fakeException("%s is not a method signature", object_getClassName(obj)))
+	}
+	return sig
+}
+
+func getFakeInvocation(obj cID) *fakeInvocation {
+	fakeForwarding.Lock()
+	inv := fakeForwarding.invs[obj]
+	fakeForwarding.Unlock()
+	if inv == nil {
+		panic(fakeException("%s is not an invocation", object_getClassName(obj)))
+	}
+	return inv
+}
+
+// isFloat checks if values of the type encoding are passed in floating point registers.
+func isFloat(enc string) bool {
+	t, err := valueType(enc)
+	return err == nil && (t == encoding.Float || t == encoding.Double)
+}
+
+// fakeValueSize returns the size of a value of the type encoding, which must fit in a register.
+func fakeValueSize(enc string) uintptr {
+	t, err := encoding.Parse(enc)
+	if err != nil {
+		panic(fakeException("%v", err))
+	}
+	size := t.Size()
+	if size > 8 {
+		panic(fakeException("unsupported type encoding: %q", enc))
+	}
+	return size
+}
+
+// fakeLoad reads a value of n bytes from memory as a register value.
+func fakeLoad(p unsafe.Pointer, n uintptr) uint64 {
+	var w uint64
+	copy((*[8]byte)(unsafe.Pointer(&w))[:n], (*[8]byte)(p)[:n])
+	return w
+}
+
+// fakeStore writes n bytes of a register value to memory.
+func fakeStore(p unsafe.Pointer, n uintptr, w uint64) {
+	copy((*[8]byte)(p)[:n], (*[8]byte)(unsafe.Pointer(&w))[:n])
+}
+
+// newFakeInvocation creates an NSInvocation for a message with arguments in registers.
+// The caller owns the returned object.
+func newFakeInvocation(sig, target cID, sel cSEL, a *callArgs) (*fakeInvocation, error) {
+	fakeForwarding.Lock()
+	fs := fakeForwarding.sigs[sig]
+	fakeForwarding.Unlock()
+	if fs == nil || len(fs.args) < 2 {
+		return nil, fmt.Errorf("objc: invalid method signature for %q", sel_getName(sel))
+	}
+	inv := &fakeInvocation{sig: sig, target: target, sel: sel}
+	ni, nf := 0, 0
+	for _, enc := range fs.args[2:] {
+		if isFloat(enc) {
+			if nf >= maxFloatArgs {
+				return nil, fmt.Errorf("objc: %q: too many floating point arguments", sel_getName(sel))
+			}
+			inv.args = append(inv.args, math.Float64bits(a.floats[nf]))
+			nf++
+		} else {
+			if ni >= maxIntArgs {
+				return nil, fmt.Errorf("objc: %q: too many integer arguments", sel_getName(sel))
+			}
+			inv.args = append(inv.args, a.ints[ni])
+			ni++
+		}
+	}
+	inv.obj = fakeAlloc(objc_getClass("NSInvocation"), 0)
+	fakeRetain(sig)
+	fakeForwarding.Lock()
+	fakeForwarding.invs[inv.obj] = inv
+	fakeForwarding.Unlock()
+	return inv, nil
+}
+
+// argSize returns the size of the argument at a given index, including the receiver and the selector.
+func (inv *fakeInvocation) argSize(i uintptr) uintptr {
+	args := getFakeSignature(inv.sig).args
+	if i >= uintptr(len(args)) {
+		panic(fakeException("index %d out of bounds [0, %d]", i, len(args)-1))
+	}
+	if i < 2 {
+		return unsafe.Sizeof(uintptr(0))
+	}
+	return fakeValueSize(args[i])
+}
+
+func (inv *fakeInvocation) getArg(i uintptr) uint64 {
+	switch i {
+	case 0:
+		return uint64(uintptr(unsafe.Pointer(inv.target)))
+	case 1:
+		return uint64(uintptr(unsafe.Pointer(inv.sel)))
+	}
+	return inv.args[i-2]
+}
+
+func (inv *fakeInvocation) setArg(i uintptr, w uint64) {
+	switch i {
+	case 0:
+		inv.target = cID(fakePointer(w))
+	case 1:
+		inv.sel = cSEL(fakePointer(w))
+	default:
+		inv.args[i-2] = w
+	}
+}
+
+// invoke sends the message to the target and stores the result.
+func (inv *fakeInvocation) invoke(target cID) {
+	inv.ret = 0
+	if target == nil {
+		return
+	}
+	var a callArgs
+	args := getFakeSignature(inv.sig).args[2:]
+	for i, enc := range args {
+		if isFloat(enc) {
+			a.floats[a.nf] = math.Float64frombits(inv.args[i])
+			a.nf++
+		} else {
+			a.ints[a.ni] = inv.args[i]
+			a.ni++
+		}
+	}
+	imp := msgLookup(target, inv.sel)
+	if imp == nil {
+		panic(fakeException("%v", fakeUnrecognized(target, inv.sel)))
+	}
+	w, err := fakeCall(imp, unsafe.Pointer(target), unsafe.Pointer(inv.sel), &a)
+	if e, ok := err.(*Exception); ok {
+		panic(e)
+	} else if err != nil {
+		panic(fakeException("%v", err))
+	}
+	inv.ret = w
+}
+
+// newFakeInvocationClasses creates NSMethodSignature and NSInvocation classes.
+func newFakeInvocationClasses(super cClass) {
+	c := objc_allocateClassPair(super, "NSMethodSignature")
+	// autorelease pools are not supported, thus signatures returned to the runtime are never released
+	addFakeMethod(c.isa, "signatureWithObjCTypes:", func(self Object, cmd Selector, types unsafe.Pointer) Object {
+		m, err := encoding.ParseMethod(goString(types))
+		if err != nil {
+			panic(fakeException("%v", err))
+		}
+		sig := &fakeSignature{ret: m.Return.String()}
+		for _, arg := range m.Args {
+			sig.args = append(sig.args, arg.Type.String())
+		}
+		obj := fakeAlloc(cClass(self.Pointer()), 0)
+		fakeForwarding.Lock()
+		fakeForwarding.sigs[obj] = sig
+		fakeForwarding.Unlock()
+		return Object{id: obj}
+	})
+	addFakeMethod(c, "numberOfArguments", func(self Object, cmd Selector) uintptr {
+		return uintptr(len(getFakeSignature(self.id).args))
+	})
+	addFakeMethod(c, "getArgumentTypeAtIndex:", func(self Object, cmd Selector, i uintptr) unsafe.Pointer {
+		args := getFakeSignature(self.id).args
+		if i >= uintptr(len(args)) {
+			panic(fakeException("index %d out of bounds [0, %d]", i, len(args)-1))
+		}
+		return cTypeString(args[i])
+	})
+	addFakeMethod(c, "methodReturnType", func(self Object, cmd Selector) unsafe.Pointer {
+		return cTypeString(getFakeSignature(self.id).ret)
+	})
+	addFakeMethod(c, "dealloc", func(self Object, cmd Selector) {
+		fakeForwarding.Lock()
+		delete(fakeForwarding.sigs, self.id)
+		fakeForwarding.Unlock()
+		fakeDispose(self.id)
+	})
+	objc_registerClassPair(c)
+
+	c = objc_allocateClassPair(super, "NSInvocation")
+	addFakeMethod(c, "methodSignature", func(self Object, cmd Selector) Object {
+		return Object{id: getFakeInvocation(self.id).sig}
+	})
+	addFakeMethod(c, "selector", func(self Object, cmd Selector) Selector {
+		return Selector{sel: getFakeInvocation(self.id).sel}
+	})
+	addFakeMethod(c, "setSelector:", func(self Object, cmd Selector, sel Selector) {
+		getFakeInvocation(self.id).sel = sel.sel
+	})
+	addFakeMethod(c, "target", func(self Object, cmd Selector) Object {
+		return Object{id: getFakeInvocation(self.id).target}
+	})
+	addFakeMethod(c, "setTarget:", func(self Object, cmd Selector, target Object) {
+		getFakeInvocation(self.id).target = target.id
+	})
+	addFakeMethod(c, "getArgument:atIndex:", func(self Object, cmd Selector, p unsafe.Pointer, i int) {
+		inv := getFakeInvocation(self.id)
+		if i < 0 {
+			panic(fakeException("index %d out of bounds", i))
+		}
+		fakeStore(p, inv.argSize(uintptr(i)), inv.getArg(uintptr(i)))
+	})
+	addFakeMethod(c, "setArgument:atIndex:", func(self Object, cmd Selector, p unsafe.Pointer, i int) {
+		inv := getFakeInvocation(self.id)
+		if i < 0 {
+			panic(fakeException("index %d out of bounds", i))
+		}
+		inv.setArg(uintptr(i), fakeLoad(p, inv.argSize(uintptr(i))))
+	})
+	addFakeMethod(c, "getReturnValue:", func(self Object, cmd Selector, p unsafe.Pointer) {
+		inv := getFakeInvocation(self.id)
+		fakeStore(p, fakeValueSize(getFakeSignature(inv.sig).ret), inv.ret)
+	})
+	addFakeMethod(c, "setReturnValue:", func(self Object, cmd Selector, p unsafe.Pointer) {
+		inv := getFakeInvocation(self.id)
+		inv.ret = fakeLoad(p, fakeValueSize(getFakeSignature(inv.sig).ret))
+	})
+	addFakeMethod(c, "invoke", func(self Object, cmd Selector) {
+		inv := getFakeInvocation(self.id)
+		inv.invoke(inv.target)
+	})
+	addFakeMethod(c, "invokeWithTarget:", func(self Object, cmd Selector, target Object) {
+		getFakeInvocation(self.id).invoke(target.id)
+	})
+	addFakeMethod(c, "dealloc", func(self Object, cmd Selector) {
+		fakeForwarding.Lock()
+		inv := fakeForwarding.invs[self.id]
+		delete(fakeForwarding.invs, self.id)
+		fakeForwarding.Unlock()
+		if inv != nil {
+			fakeRelease(inv.sig)
+		}
+		fakeDispose(self.id)
+	})
+	objc_registerClassPair(c)
+}
@@ -0,0 +1,127 @@
+package objc
+
+import "sort"
+
+// Metaclass returns the metaclass of a class. Metaclasses implement class methods.
+// For metaclasses, it returns the metaclass of the root class.
+//
+// See object_getClass.
+func (c *Class) Metaclass() *Class {
+	if !c.Valid() {
+		return nil
+	}
+	m := object_getClass(c.Object().id)
+	if m == nil {
+		return nil
+	}
+	return &Class{class: m}
+}
+
+// IsSubclassOf checks if the class inherits from the other class.
+// A class is considered a subclass of itself.
+func (c *Class) IsSubclassOf(other *Class) bool {
+	if !c.Valid() || !other.Valid() {
+		return false
+	}
+	for s := c.class; s != nil; s = class_getSuperclass(s) {
+		if s == other.class {
+			return true
+		}
+	}
+	return false
+}
+
+// Hierarchy returns the class followed by the chain of its superclasses, ending with the root class.
+func (c *Class) Hierarchy() []Class {
+	if !c.Valid() {
+		return nil
+	}
+	var out []Class
+	for s := c.class; s != nil; s = class_getSuperclass(s) {
+		out = append(out, Class{class: s})
+	}
+	return out
+}
+
+// Subclasses returns direct subclasses of the class, sorted by name.
+//
+// It lists all registered classes, thus NewClassTree should be used for repeated queries.
+func (c *Class) Subclasses() []Class {
+	if !c.Valid() {
+		return nil
+	}
+	var out []Class
+	Classes(func(s Class) bool {
+		if class_getSuperclass(s.class) == c.class {
+			out = append(out, s)
+		}
+		return true
+	})
+	sortClasses(out)
+	return out
+}
+
+func sortClasses(list []Class) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+}
+
+// ClassTree is a snapshot of the class hierarchy.
+// It doesn't reflect classes registered after it was created.
+type ClassTree struct {
+	roots    []Class
+	children map[cClass][]Class
+}
+
+// NewClassTree builds a tree of all registered classes.
+func NewClassTree() *ClassTree {
+	t := &ClassTree{children: make(map[cClass][]Class)}
+	for _, c := range CachedClasses() {
+		if s := class_getSuperclass(c.class); s != nil {
+			t.children[s] = append(t.children[s], c)
+		} else {
+			t.roots = append(t.roots, c)
+		}
+	}
+	sortClasses(t.roots)
+	for _, list := range t.children {
+		sortClasses(list)
+	}
+	return t
+}
+
+// Roots returns root classes, sorted by name.
+func (t *ClassTree) Roots() []Class {
+	return append([]Class(nil), t.roots...)
+}
+
+// Subclasses returns direct subclasses of a class, sorted by name.
+func (t *ClassTree) Subclasses(c *Class) []Class {
+	if !c.Valid() {
+		return nil
+	}
+	return append([]Class(nil), t.children[c.class]...)
+}
+
+// Walk calls the function for each class in the tree in depth-first order, starting from the roots.
+// Depth is zero for root classes. If the function returns false, subclasses of the class are skipped.
+func (t *ClassTree) Walk(fnc func(c Class, depth int) bool) {
+	t.walk(t.roots, 0, fnc)
+}
+
+// WalkFrom is like Walk, but only visits the class and its subclasses.
+func (t *ClassTree) WalkFrom(c *Class, fnc func(c Class, depth int) bool) {
+	if !c.Valid() {
+		return
+	}
+	t.walk([]Class{*c}, 0, fnc)
+}
+
+func (t *ClassTree) walk(list []Class, depth int, fnc func(c Class, depth int) bool) {
+	for _, c := range list {
+		if fnc(c, depth) {
+			t.walk(t.children[c.class], depth+1, fnc)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package objc
+
+// ImageName returns the name of the dynamic library or framework the class originated from.
+// It returns an empty string if the image is unknown or the runtime doesn't provide this information.
+//
+// See class_getImageName.
+func (c *Class) ImageName() string {
+	if !c.Valid() {
+		return ""
+	}
+	return class_getImageName(c.class)
+}
+
+// ImageNames returns names of all loaded images that contain Objective-C classes.
+//
+// See objc_copyImageNames.
+func ImageNames() []string {
+	return objc_copyImageNames()
+}
+
+// ClassNamesForImage returns names of all classes provided by a given image.
+// The image name must match the one returned by ImageNames or Class.ImageName.
+//
+// See objc_copyClassNamesForImage.
+func ClassNamesForImage(image string) []string {
+	return objc_copyClassNamesForImage(image)
+}
+
+// ClassesForImage returns all classes provided by a given image.
+// See ClassNamesForImage for details.
+func ClassesForImage(image string) []Class {
+	names := ClassNamesForImage(image)
+	out := make([]Class, 0, len(names))
+	for _, name := range names {
+		if c := GetClass(name); c != nil {
+			out = append(out, *c)
+		}
+	}
+	return out
+}
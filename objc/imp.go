@@ -0,0 +1,311 @@
+package objc
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+var (
+	objectType   = reflect.TypeOf(Object{})
+	classType    = reflect.TypeOf((*Class)(nil))
+	selectorType = reflect.TypeOf(Selector{})
+	pointerType  = reflect.TypeOf(unsafe.Pointer(nil))
+)
+
+// typeEncodingOf returns the type encoding for a Go type.
+func typeEncodingOf(t reflect.Type) (string, error) {
+	switch t {
+	case objectType:
+		return "@", nil
+	case classType:
+		return "#", nil
+	case selectorType:
+		return ":", nil
+	case pointerType:
+		return "^v", nil
+	}
+	const is64 = unsafe.Sizeof(uintptr(0)) == 8
+	switch t.Kind() {
+	case reflect.Bool:
+		return "B", nil
+	case reflect.Int8:
+		return "c", nil
+	case reflect.Int16:
+		return "s", nil
+	case reflect.Int32:
+		return "i", nil
+	case reflect.Int64:
+		return "q", nil
+	case reflect.Int:
+		if is64 {
+			return "q", nil
+		}
+		return "i", nil
+	case reflect.Uint8:
+		return "C", nil
+	case reflect.Uint16:
+		return "S", nil
+	case reflect.Uint32:
+		return "I", nil
+	case reflect.Uint64:
+		return "Q", nil
+	case reflect.Uint, reflect.Uintptr:
+		if is64 {
+			return "Q", nil
+		}
+		return "I", nil
+	case reflect.Float32:
+		return "f", nil
+	case reflect.Float64:
+		return "d", nil
+	}
+	return "", fmt.Errorf("unsupported type: %v", t)
+}
+
+// goMethod is a method or a block implemented in Go.
+type goMethod struct {
+	fnc   reflect.Value
+	args  []string // type encodings of arguments, excluding self and _cmd
+	ret   string   // type encoding of the result
+	block bool     // function is a block; it doesn't accept self and _cmd
+}
+
+// newGoMethod checks the signature of a Go function and creates a method for it.
+//
+// The function must accept the receiver and the selector as the first two arguments,
+// followed by the method arguments. It may return at most one value.
+func newGoMethod(fnc interface{}) (*goMethod, error) {
+	rv := reflect.ValueOf(fnc)
+	if rv.Kind() != reflect.Func || rv.IsNil() {
+		return nil, fmt.Errorf("expected a function, got %T", fnc)
+	}
+	return newGoMethodValue(rv)
+}
+
+func newGoMethodValue(rv reflect.Value) (*goMethod, error) {
+	rt := rv.Type()
+	if rt.NumIn() < 2 || rt.In(0) != objectType || rt.In(1) != selectorType {
+		return nil, fmt.Errorf("function must accept Object and Selector as first arguments: %v", rt)
+	}
+	return newGoFunc(rv, false)
+}
+
+// newGoBlock checks the signature of a Go function and creates a block implementation for it.
+func newGoBlock(fnc interface{}) (*goMethod, error) {
+	rv := reflect.ValueOf(fnc)
+	if rv.Kind() != reflect.Func || rv.IsNil() {
+		return nil, fmt.Errorf("expected a function, got %T", fnc)
+	}
+	return newGoFunc(rv, true)
+}
+
+// newGoFunc checks argument and result types of a function.
+func newGoFunc(rv reflect.Value, block bool) (*goMethod, error) {
+	rt := rv.Type()
+	if rt.IsVariadic() {
+		return nil, fmt.Errorf("variadic functions are not supported")
+	} else if rt.NumOut() > 1 {
+		return nil, fmt.Errorf("function must return at most one value: %v", rt)
+	}
+	m := &goMethod{fnc: rv, ret: "v", block: block}
+	ni, nf := 0, 0
+	for i := m.skip(); i < rt.NumIn(); i++ {
+		enc, err := typeEncodingOf(rt.In(i))
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %v", i-m.skip(), err)
+		}
+		if enc == "f" || enc == "d" {
+			nf++
+		} else {
+			ni++
+		}
+		m.args = append(m.args, enc)
+	}
+	if ni > maxIntArgs {
+		return nil, fmt.Errorf("too many integer arguments: %d", ni)
+	} else if nf > maxFloatArgs {
+		return nil, fmt.Errorf("too many floating point arguments: %d", nf)
+	}
+	if rt.NumOut() == 1 {
+		enc, err := typeEncodingOf(rt.Out(0))
+		if err != nil {
+			return nil, fmt.Errorf("result: %v", err)
+		}
+		m.ret = enc
+	}
+	return m, nil
+}
+
+// skip returns the number of implicit arguments of the function.
+func (m *goMethod) skip() int {
+	if m.block {
+		return 0
+	}
+	return 2
+}
+
+// types returns the method or block type encoding.
+func (m *goMethod) types() string {
+	s := m.ret + "@:"
+	if m.block {
+		s = m.ret + "@?"
+	}
+	for _, a := range m.args {
+		s += a
+	}
+	return s
+}
+
+// imp returns a trampoline that should be used as an IMP for the method added to a given class.
+func (m *goMethod) imp(c cClass) (unsafe.Pointer, error) {
+	imp := impTrampoline(m.ret, classDepth(c))
+	if imp == nil {
+		return nil, fmt.Errorf("class %s is too deep in the hierarchy", class_getName(c))
+	}
+	return imp, nil
+}
+
+// classDepth returns the number of superclasses of the class.
+func classDepth(c cClass) int {
+	n := 0
+	for c = class_getSuperclass(c); c != nil; c = class_getSuperclass(c) {
+		n++
+	}
+	return n
+}
+
+// call decodes arguments from registers, calls the function and returns the result register value.
+// The receiver and the selector are ignored for blocks.
+//
+// It panics if arguments or the result cannot be converted. When called from Objective-C,
+// the panic is recovered by recoverCallback.
+func (m *goMethod) call(self Object, cmd Selector, ints *[maxIntArgs]uint64, floats *[maxFloatArgs]float64) uint64 {
+	rt := m.fnc.Type()
+	in := make([]reflect.Value, 0, 2+len(m.args))
+	if !m.block {
+		in = append(in, reflect.ValueOf(self), reflect.ValueOf(cmd))
+	}
+	ni, nf := 0, 0
+	for i, enc := range m.args {
+		var w uint64
+		if enc == "f" || enc == "d" {
+			w = math.Float64bits(floats[nf])
+			nf++
+		} else {
+			w = ints[ni]
+			ni++
+		}
+		v, err := readValue(unsafe.Pointer(&w), enc)
+		if err != nil {
+			panic(fmt.Errorf("objc: %q: argument %d: %v", cmd.Name(), i, err))
+		}
+		in = append(in, reflect.ValueOf(v).Convert(rt.In(i+m.skip())))
+	}
+	out := m.fnc.Call(in)
+	if len(out) == 0 {
+		return 0
+	}
+	var a callArgs
+	if err := a.add(m.ret, out[0].Interface()); err != nil {
+		panic(fmt.Errorf("objc: %q: result: %v", cmd.Name(), err))
+	}
+	if a.nf != 0 {
+		return math.Float64bits(a.floats[0])
+	}
+	return a.ints[0]
+}
+
+type impKey struct {
+	class cClass
+	sel   string
+}
+
+// goMethods is a registry of methods implemented in Go.
+var goMethods = struct {
+	sync.RWMutex
+	byKey map[impKey]*goMethod
+}{
+	byKey: make(map[impKey]*goMethod),
+}
+
+// getGoMethod returns a Go method registered for a given class and selector.
+func getGoMethod(c cClass, sel string) *goMethod {
+	goMethods.RLock()
+	defer goMethods.RUnlock()
+	return goMethods.byKey[impKey{class: c, sel: sel}]
+}
+
+func setGoMethod(c cClass, sel string, m *goMethod) {
+	goMethods.Lock()
+	if m == nil {
+		delete(goMethods.byKey, impKey{class: c, sel: sel})
+	} else {
+		goMethods.byKey[impKey{class: c, sel: sel}] = m
+	}
+	goMethods.Unlock()
+}
+
+// removeGoMethods removes all Go methods of a given class.
+func removeGoMethods(c cClass) {
+	goMethods.Lock()
+	for k := range goMethods.byKey {
+		if k.class == c {
+			delete(goMethods.byKey, k)
+		}
+	}
+	goMethods.Unlock()
+}
+
+// findGoMethod finds a Go method implementation for a given receiver class and selector.
+// Superclasses are searched as well, since the method might be inherited.
+func findGoMethod(c cClass, sel string) *goMethod {
+	goMethods.RLock()
+	defer goMethods.RUnlock()
+	for ; c != nil; c = class_getSuperclass(c) {
+		if m := goMethods.byKey[impKey{class: c, sel: sel}]; m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// callGoImp calls a Go method implementation for a given receiver and selector.
+// It is called by IMP trampolines and returns the result register value.
+// It panics if the method is not found, see also goMethod.call.
+//
+// The method is looked up in the class it was added to, which is the ancestor of the receiver
+// class at a given depth. Looking it up from the receiver class instead would call the method
+// of a subclass again when the method is called on the superclass.
+func callGoImp(depth int, self, cmd unsafe.Pointer, ints *[maxIntArgs]uint64, floats *[maxFloatArgs]float64) uint64 {
+	obj := Object{id: cID(self)}
+	sel := Selector{sel: cSEL(cmd)}
+	c := object_getClass(obj.id)
+	for n := classDepth(c) - depth; n > 0; n-- {
+		c = class_getSuperclass(c)
+	}
+	m := getGoMethod(c, sel.Name())
+	if m == nil {
+		panic(fmt.Errorf("objc: no Go implementation of %q for %s", sel.Name(), obj.Class()))
+	}
+	return m.call(obj, sel, ints, floats)
+}
+
+// recoverCallback recovers from a panic in a Go method or block called from Objective-C.
+// Panics must not unwind through C frames, thus the error is logged and a zero result is returned.
+// The selector is nil for blocks.
+func recoverCallback(cmd unsafe.Pointer, out *uint64) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	*out = 0
+	name := "block"
+	if cmd != nil {
+		name = fmt.Sprintf("%q", Selector{sel: cSEL(cmd)}.Name())
+	}
+	log.Printf("objc: panic in Go implementation of %s: %v", name, r)
+}
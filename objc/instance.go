@@ -0,0 +1,128 @@
+package objc
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// CreateInstance allocates an instance of the class with a given number of extra bytes
+// after the instance variables, see Object.IndexedIvars.
+//
+// The instance is zeroed and no messages are sent to it, in particular alloc and init are not called.
+// The caller owns the returned reference and must destroy the object with Release or Dispose.
+//
+// See class_createInstance.
+func (c *Class) CreateInstance(extraBytes uintptr) (Object, error) {
+	if !c.Valid() {
+		return Object{}, fmt.Errorf("objc: create instance of nil class")
+	}
+	obj := class_createInstance(c.class, extraBytes)
+	if obj == nil {
+		return Object{}, fmt.Errorf("objc: cannot create instance of %s", c.Name())
+	}
+	return Object{id: obj}, nil
+}
+
+// Copy creates a shallow copy of the object with a given number of extra bytes.
+// Referenced objects are not retained and Go values bound to the object are not copied.
+// The caller owns the returned reference.
+//
+// See object_copy.
+func (o Object) Copy(extraBytes uintptr) (Object, error) {
+	if o.IsNil() {
+		return Object{}, fmt.Errorf("objc: copy nil object")
+	}
+	obj := object_copy(o.id, extraBytes)
+	if obj == nil {
+		return Object{}, fmt.Errorf("objc: cannot copy %v", o)
+	}
+	cp := Object{id: obj}
+	for _, gc := range findGoClasses(object_getClass(obj)) {
+		// the handle belongs to the original object
+		*gc.handlePtr(cp) = 0
+	}
+	return cp, nil
+}
+
+// Dispose destroys the object and frees its memory without sending dealloc.
+// Go values bound to the object, if any, are released.
+// The object must not be used after this call.
+//
+// See object_dispose.
+func (o Object) Dispose() {
+	if o.IsNil() {
+		return
+	}
+	for _, gc := range findGoClasses(object_getClass(o.id)) {
+		gc.release(o)
+	}
+	object_dispose(o.id)
+}
+
+// SetClass changes the class of the object and returns the previous one.
+// The new class must have a compatible layout of instance variables, usually
+// it's a subclass of the current class without additional ivars.
+//
+// See object_setClass.
+func (o Object) SetClass(c *Class) *Class {
+	if o.IsNil() || !c.Valid() {
+		return nil
+	}
+	prev := object_setClass(o.id, c.class)
+	if prev == nil {
+		return nil
+	}
+	return &Class{class: prev}
+}
+
+// ClassName returns the name of the object's class.
+//
+// See object_getClassName.
+func (o Object) ClassName() string {
+	if o.IsNil() {
+		return "nil"
+	}
+	return object_getClassName(o.id)
+}
+
+// IndexedIvars returns a pointer to the extra bytes allocated with the object.
+// The result is undefined if the object was allocated without extra bytes.
+//
+// See object_getIndexedIvars.
+func (o Object) IndexedIvars() unsafe.Pointer {
+	if o.IsNil() {
+		return nil
+	}
+	return object_getIndexedIvars(o.id)
+}
+
+// Instance is an object created with CreateInstance that keeps track of its extra bytes.
+type Instance struct {
+	Object
+	extra uintptr
+}
+
+// NewInstance creates an instance of the class with a given number of extra bytes.
+// See Class.CreateInstance for details.
+func NewInstance(c *Class, extraBytes uintptr) (*Instance, error) {
+	obj, err := c.CreateInstance(extraBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &Instance{Object: obj, extra: extraBytes}, nil
+}
+
+// ExtraSize returns the number of extra bytes allocated with the instance.
+func (i *Instance) ExtraSize() uintptr {
+	return i.extra
+}
+
+// Extra returns the extra bytes allocated with the instance.
+// The slice refers to the object memory and must not be used after the object is destroyed.
+func (i *Instance) Extra() []byte {
+	if i.extra == 0 || i.IsNil() {
+		return nil
+	}
+	n := i.extra
+	return (*[1 << 28]byte)(i.IndexedIvars())[:n:n]
+}
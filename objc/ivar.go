@@ -0,0 +1,403 @@
+package objc
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"github.com/dennwc/go-apple/objc/encoding"
+)
+
+// Ivar describes an instance variable of a class.
+type Ivar struct {
+	ivar cIvar
+}
+
+func (v *Ivar) Valid() bool {
+	return v != nil && v.ivar != nil
+}
+
+func (v Ivar) String() string {
+	if !v.Valid() {
+		return "<nil>"
+	}
+	return v.Name() + " " + v.TypeEncoding()
+}
+
+// Name returns the name of an instance variable.
+//
+// See ivar_getName.
+func (v Ivar) Name() string {
+	if !v.Valid() {
+		return ""
+	}
+	return ivar_getName(v.ivar)
+}
+
+// Offset returns the offset of an instance variable from the start of the object.
+//
+// See ivar_getOffset.
+func (v Ivar) Offset() uintptr {
+	if !v.Valid() {
+		return 0
+	}
+	return ivar_getOffset(v.ivar)
+}
+
+// TypeEncoding returns the type string of an instance variable.
+//
+// See ivar_getTypeEncoding.
+func (v Ivar) TypeEncoding() string {
+	if !v.Valid() {
+		return ""
+	}
+	return ivar_getTypeEncoding(v.ivar)
+}
+
+// Type returns the parsed type encoding of an instance variable.
+func (v Ivar) Type() (encoding.Type, error) {
+	if !v.Valid() {
+		return nil, fmt.Errorf("objc: invalid ivar")
+	}
+	return encoding.Parse(v.TypeEncoding())
+}
+
+// Ivars returns instance variables declared by the class.
+// Instance variables declared by superclasses are not included.
+//
+// See class_copyIvarList.
+func (c *Class) Ivars() []Ivar {
+	if !c.Valid() {
+		return nil
+	}
+	list := class_copyIvarList(c.class)
+	if list == nil {
+		return nil
+	}
+	out := make([]Ivar, 0, len(list))
+	for _, v := range list {
+		out = append(out, Ivar{ivar: v})
+	}
+	return out
+}
+
+// Ivar returns an instance variable with a given name.
+// Superclasses are searched as well.
+//
+// See class_getInstanceVariable.
+func (c *Class) Ivar(name string) *Ivar {
+	if !c.Valid() {
+		return nil
+	}
+	v := class_getInstanceVariable(c.class, name)
+	if v == nil {
+		return nil
+	}
+	return &Ivar{ivar: v}
+}
+
+func (o Object) ivar(name string) (*Ivar, error) {
+	if o.IsNil() {
+		return nil, fmt.Errorf("objc: get ivar %q of nil object", name)
+	}
+	c := o.Class()
+	v := c.Ivar(name)
+	if v == nil {
+		return nil, fmt.Errorf("objc: class %s has no ivar %q", c, name)
+	}
+	return v, nil
+}
+
+// GetIvar reads the value of an instance variable of the object.
+//
+// The Go type of the value is determined by the type encoding of the variable:
+// integer and floating point types are mapped to corresponding Go types,
+// objects are returned as Object, classes as *Class, selectors as Selector,
+// and pointers as unsafe.Pointer. Structures, unions, arrays and bit fields
+// are not supported.
+func (o Object) GetIvar(name string) (interface{}, error) {
+	v, err := o.ivar(name)
+	if err != nil {
+		return nil, err
+	}
+	p := incPtr(o.Pointer(), v.Offset())
+	out, err := readValue(p, v.TypeEncoding())
+	if err != nil {
+		return nil, fmt.Errorf("objc: ivar %q: %v", name, err)
+	}
+	return out, nil
+}
+
+// SetIvar writes the value of an instance variable of the object.
+//
+// See GetIvar for the list of supported types. Integer and floating point values
+// are converted to the type of the variable if they fit into it. Nil can be used for
+// objects, classes, selectors and pointers.
+//
+// Objects are assigned without being retained.
+func (o Object) SetIvar(name string, val interface{}) error {
+	v, err := o.ivar(name)
+	if err != nil {
+		return err
+	}
+	p := incPtr(o.Pointer(), v.Offset())
+	if err = writeValue(p, v.TypeEncoding(), val); err != nil {
+		return fmt.Errorf("objc: ivar %q: %v", name, err)
+	}
+	return nil
+}
+
+// valueType parses the type encoding of a value and removes method type qualifiers.
+func valueType(enc string) (encoding.Type, error) {
+	t, err := encoding.Parse(enc)
+	if err != nil {
+		return nil, err
+	}
+	return encoding.Unqualified(t), nil
+}
+
+// readValue reads a value of a given type encoding from memory.
+func readValue(p unsafe.Pointer, enc string) (interface{}, error) {
+	t, err := valueType(enc)
+	if err != nil {
+		return nil, err
+	}
+	return readType(p, t)
+}
+
+// writeValue writes a value of a given type encoding to memory.
+func writeValue(p unsafe.Pointer, enc string, val interface{}) error {
+	t, err := valueType(enc)
+	if err != nil {
+		return err
+	}
+	return writeType(p, t, val)
+}
+
+// readType reads a value of a given unqualified type from memory.
+func readType(p unsafe.Pointer, t encoding.Type) (interface{}, error) {
+	switch t := t.(type) {
+	case encoding.Basic:
+		switch {
+		case t.IsInteger() && t.IsUnsigned():
+			switch t.Size() {
+			case 1:
+				return *(*uint8)(p), nil
+			case 2:
+				return *(*uint16)(p), nil
+			case 4:
+				return *(*uint32)(p), nil
+			case 8:
+				return *(*uint64)(p), nil
+			}
+		case t.IsInteger():
+			switch t.Size() {
+			case 1:
+				return *(*int8)(p), nil
+			case 2:
+				return *(*int16)(p), nil
+			case 4:
+				return *(*int32)(p), nil
+			case 8:
+				return *(*int64)(p), nil
+			}
+		}
+		switch t {
+		case encoding.Float:
+			return *(*float32)(p), nil
+		case encoding.Double:
+			return *(*float64)(p), nil
+		case encoding.Bool:
+			return *(*uint8)(p) != 0, nil
+		case encoding.Class:
+			c := *(*cClass)(p)
+			if c == nil {
+				return (*Class)(nil), nil
+			}
+			return &Class{class: c}, nil
+		case encoding.Selector:
+			return Selector{sel: *(*cSEL)(p)}, nil
+		case encoding.CString:
+			return *(*unsafe.Pointer)(p), nil
+		}
+	case encoding.Object, encoding.Block:
+		return Object{id: *(*cID)(p)}, nil
+	case encoding.Pointer:
+		return *(*unsafe.Pointer)(p), nil
+	}
+	return nil, fmt.Errorf("unsupported type encoding: %q", t)
+}
+
+// isPointer checks if values of the type are pointers that can be set to nil.
+func isPointer(t encoding.Type) bool {
+	switch t {
+	case encoding.Class, encoding.Selector, encoding.CString:
+		return true
+	}
+	switch t.(type) {
+	case encoding.Object, encoding.Block, encoding.Pointer:
+		return true
+	}
+	return false
+}
+
+// writeType writes a value of a given unqualified type to memory.
+func writeType(p unsafe.Pointer, t encoding.Type, val interface{}) error {
+	if val == nil && isPointer(t) {
+		*(*unsafe.Pointer)(p) = nil
+		return nil
+	}
+	switch t := t.(type) {
+	case encoding.Basic:
+		switch {
+		case t.IsInteger() && t.IsUnsigned():
+			v, err := uintValue(val, t)
+			if err != nil {
+				return err
+			}
+			switch t.Size() {
+			case 1:
+				*(*uint8)(p) = uint8(v)
+			case 2:
+				*(*uint16)(p) = uint16(v)
+			case 4:
+				*(*uint32)(p) = uint32(v)
+			case 8:
+				*(*uint64)(p) = v
+			default:
+				return fmt.Errorf("unsupported type encoding: %q", t)
+			}
+			return nil
+		case t.IsInteger():
+			v, err := intValue(val, t)
+			if err != nil {
+				return err
+			}
+			switch t.Size() {
+			case 1:
+				*(*int8)(p) = int8(v)
+			case 2:
+				*(*int16)(p) = int16(v)
+			case 4:
+				*(*int32)(p) = int32(v)
+			case 8:
+				*(*int64)(p) = v
+			default:
+				return fmt.Errorf("unsupported type encoding: %q", t)
+			}
+			return nil
+		}
+		switch t {
+		case encoding.Float, encoding.Double:
+			rv := reflect.ValueOf(val)
+			switch rv.Kind() {
+			case reflect.Float32, reflect.Float64:
+			default:
+				return fmt.Errorf("cannot use %T as %q", val, t)
+			}
+			if t == encoding.Float {
+				*(*float32)(p) = float32(rv.Float())
+			} else {
+				*(*float64)(p) = rv.Float()
+			}
+			return nil
+		case encoding.Bool:
+			v, ok := val.(bool)
+			if !ok {
+				return fmt.Errorf("cannot use %T as %q", val, t)
+			}
+			var b uint8
+			if v {
+				b = 1
+			}
+			*(*uint8)(p) = b
+			return nil
+		case encoding.Class:
+			v, ok := val.(*Class)
+			if !ok {
+				return fmt.Errorf("cannot use %T as %q", val, t)
+			}
+			var c cClass
+			if v != nil {
+				c = v.class
+			}
+			*(*cClass)(p) = c
+			return nil
+		case encoding.Selector:
+			v, ok := val.(Selector)
+			if !ok {
+				return fmt.Errorf("cannot use %T as %q", val, t)
+			}
+			*(*cSEL)(p) = v.sel
+			return nil
+		case encoding.CString:
+			v, ok := val.(unsafe.Pointer)
+			if !ok {
+				return fmt.Errorf("cannot use %T as %q", val, t)
+			}
+			*(*unsafe.Pointer)(p) = v
+			return nil
+		}
+	case encoding.Object, encoding.Block:
+		v, ok := val.(Object)
+		if !ok {
+			return fmt.Errorf("cannot use %T as %q", val, t)
+		}
+		*(*cID)(p) = v.id
+		return nil
+	case encoding.Pointer:
+		v, ok := val.(unsafe.Pointer)
+		if !ok {
+			return fmt.Errorf("cannot use %T as %q", val, t)
+		}
+		*(*unsafe.Pointer)(p) = v
+		return nil
+	}
+	return fmt.Errorf("unsupported type encoding: %q", t)
+}
+
+// intValue converts a Go integer to a value of a signed integer type, checking for overflows.
+func intValue(val interface{}, t encoding.Basic) (int64, error) {
+	rv := reflect.ValueOf(val)
+	var v int64
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v = rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > 1<<63-1 {
+			return 0, fmt.Errorf("value %d overflows %q", u, t)
+		}
+		v = int64(u)
+	default:
+		return 0, fmt.Errorf("cannot use %T as %q", val, t)
+	}
+	if bits := 8 * uint(t.Size()); bits < 64 {
+		if lo, hi := int64(-1)<<(bits-1), int64(1)<<(bits-1)-1; v < lo || v > hi {
+			return 0, fmt.Errorf("value %d overflows %q", v, t)
+		}
+	}
+	return v, nil
+}
+
+// uintValue converts a Go integer to a value of an unsigned integer type, checking for overflows.
+func uintValue(val interface{}, t encoding.Basic) (uint64, error) {
+	rv := reflect.ValueOf(val)
+	var v uint64
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		if i < 0 {
+			return 0, fmt.Errorf("value %d overflows %q", i, t)
+		}
+		v = uint64(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v = rv.Uint()
+	default:
+		return 0, fmt.Errorf("cannot use %T as %q", val, t)
+	}
+	if bits := 8 * uint(t.Size()); bits < 64 && v > uint64(1)<<bits-1 {
+		return 0, fmt.Errorf("value %d overflows %q", v, t)
+	}
+	return v, nil
+}
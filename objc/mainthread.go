@@ -0,0 +1,114 @@
+package objc
+
+import (
+	"runtime"
+	"sync"
+)
+
+// mainThread is an identifier of the initial OS thread of the process.
+var mainThread uintptr
+
+func init() {
+	// init functions run on the main goroutine, which starts on the initial OS thread;
+	// keep it there, since many Objective-C frameworks must only be used from that thread
+	runtime.LockOSThread()
+	mainThread = threadID()
+}
+
+// mainQueue holds functions scheduled to run on the main thread.
+var mainQueue = struct {
+	sync.Mutex
+	funcs []func()
+	wake  chan struct{}
+}{
+	wake: make(chan struct{}, 1),
+}
+
+// IsMainThread checks if the caller runs on the main thread.
+func IsMainThread() bool {
+	return threadID() == mainThread
+}
+
+// MainThread runs the function in a new goroutine and serves calls scheduled with OnMain
+// and OnMainSync on the main thread until the function returns.
+//
+// It must be called from the main goroutine, usually at the start of main.
+// Calls scheduled before the function returns are completed before MainThread returns.
+// It panics if called on a different thread.
+func MainThread(fnc func()) {
+	if !IsMainThread() {
+		panic("objc: MainThread must be called from the main goroutine")
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fnc()
+	}()
+	serveMain(done)
+}
+
+// serveMain runs scheduled functions on the calling thread until done is closed.
+func serveMain(done <-chan struct{}) {
+	for {
+		runMain()
+		select {
+		case <-mainQueue.wake:
+		case <-done:
+			runMain()
+			return
+		}
+	}
+}
+
+// runMain runs all scheduled functions.
+func runMain() {
+	for {
+		mainQueue.Lock()
+		funcs := mainQueue.funcs
+		mainQueue.funcs = nil
+		mainQueue.Unlock()
+		if len(funcs) == 0 {
+			return
+		}
+		for _, fnc := range funcs {
+			fnc()
+		}
+	}
+}
+
+// OnMain schedules the function to run on the main thread and returns immediately.
+// Functions run in the order they were scheduled.
+//
+// Scheduled functions only run while MainThread is active. A panic in the function
+// terminates MainThread.
+func OnMain(fnc func()) {
+	mainQueue.Lock()
+	mainQueue.funcs = append(mainQueue.funcs, fnc)
+	mainQueue.Unlock()
+	select {
+	case mainQueue.wake <- struct{}{}:
+	default:
+	}
+}
+
+// OnMainSync runs the function on the main thread and waits for it to return.
+// If the caller is already on the main thread, the function is called directly.
+//
+// A panic in the function is propagated to the caller.
+// It blocks forever if MainThread is not active, see OnMain.
+func OnMainSync(fnc func()) {
+	if IsMainThread() {
+		fnc()
+		return
+	}
+	done := make(chan interface{}, 1)
+	OnMain(func() {
+		defer func() {
+			done <- recover()
+		}()
+		fnc()
+	})
+	if r := <-done; r != nil {
+		panic(r)
+	}
+}
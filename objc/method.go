@@ -0,0 +1,156 @@
+package objc
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/dennwc/go-apple/objc/encoding"
+)
+
+// IMP is a pointer to the function that implements a method.
+type IMP unsafe.Pointer
+
+// Method describes a method defined by a class.
+type Method struct {
+	method cMethod
+}
+
+func (m *Method) Valid() bool {
+	return m != nil && m.method != nil
+}
+
+func (m Method) String() string {
+	if !m.Valid() {
+		return "<nil>"
+	}
+	return m.Name() + " " + m.TypeEncoding()
+}
+
+// Selector returns the selector of a method.
+//
+// See method_getName.
+func (m Method) Selector() Selector {
+	if !m.Valid() {
+		return Selector{}
+	}
+	return Selector{sel: method_getName(m.method)}
+}
+
+// Name returns the name of the method selector.
+func (m Method) Name() string {
+	return m.Selector().Name()
+}
+
+// TypeEncoding returns a string describing a method's parameter and return types.
+//
+// See method_getTypeEncoding.
+func (m Method) TypeEncoding() string {
+	if !m.Valid() {
+		return ""
+	}
+	return method_getTypeEncoding(m.method)
+}
+
+// Signature returns the parsed type encoding of a method.
+func (m Method) Signature() (*encoding.Method, error) {
+	if !m.Valid() {
+		return nil, fmt.Errorf("objc: invalid method")
+	}
+	return encoding.ParseMethod(m.TypeEncoding())
+}
+
+// NumArguments returns the number of arguments accepted by a method,
+// including the implicit receiver and selector arguments.
+//
+// See method_getNumberOfArguments.
+func (m Method) NumArguments() int {
+	if !m.Valid() {
+		return 0
+	}
+	return method_getNumberOfArguments(m.method)
+}
+
+// Implementation returns the implementation of a method.
+//
+// See method_getImplementation.
+func (m Method) Implementation() IMP {
+	if !m.Valid() {
+		return nil
+	}
+	return IMP(method_getImplementation(m.method))
+}
+
+// SetImplementation sets the implementation of a method and returns the previous one.
+//
+// See method_setImplementation.
+func (m Method) SetImplementation(imp IMP) IMP {
+	if !m.Valid() || imp == nil {
+		return nil
+	}
+	return IMP(method_setImplementation(m.method, unsafe.Pointer(imp)))
+}
+
+// ExchangeImplementations exchanges the implementations of two methods.
+//
+// Go implementations are found by the class and selector of the message,
+// thus methods implemented in Go must not be exchanged.
+// Use Class.ReplaceMethod to intercept a method with a Go function instead.
+//
+// See method_exchangeImplementations.
+func ExchangeImplementations(m1, m2 *Method) {
+	if !m1.Valid() || !m2.Valid() {
+		return
+	}
+	method_exchangeImplementations(m1.method, m2.method)
+}
+
+// Methods returns instance methods implemented by the class.
+// Methods implemented by superclasses are not included.
+//
+// Class methods are implemented by the metaclass and are not listed either.
+//
+// See class_copyMethodList.
+func (c *Class) Methods() []Method {
+	if !c.Valid() {
+		return nil
+	}
+	list := class_copyMethodList(c.class)
+	if list == nil {
+		return nil
+	}
+	out := make([]Method, 0, len(list))
+	for _, m := range list {
+		out = append(out, Method{method: m})
+	}
+	return out
+}
+
+// InstanceMethod returns a specified instance method for a class.
+// Superclasses are searched as well.
+//
+// See class_getInstanceMethod.
+func (c *Class) InstanceMethod(sel string) *Method {
+	if !c.Valid() {
+		return nil
+	}
+	m := class_getInstanceMethod(c.class, RegisterSelector(sel).sel)
+	if m == nil {
+		return nil
+	}
+	return &Method{method: m}
+}
+
+// ClassMethod returns a specified class method for a class.
+// Superclasses are searched as well.
+//
+// See class_getClassMethod.
+func (c *Class) ClassMethod(sel string) *Method {
+	if !c.Valid() {
+		return nil
+	}
+	m := class_getClassMethod(c.class, RegisterSelector(sel).sel)
+	if m == nil {
+		return nil
+	}
+	return &Method{method: m}
+}
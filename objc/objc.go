@@ -1,16 +1,10 @@
 package objc
 
-import "C"
-
-import "unsafe"
-
 // GetClass returns the class definition of a specified class.
 //
 // See https://developer.apple.com/documentation/objectivec/1418952-objc_getclass?language=objc
 func GetClass(name string) *Class {
-	cstr := C.CString(name)
-	c := objc_getClass(cstr)
-	freeString(cstr)
+	c := objc_getClass(name)
 	if c == nil {
 		return nil
 	}
@@ -21,23 +15,14 @@ func GetClass(name string) *Class {
 //
 // See https://developer.apple.com/documentation/objectivec/1418579-objc_getclasslist?language=objc
 func ListClasses() []Class {
-	n := objc_getClassList(nil, 0)
-	if n == 0 {
+	buf := readClasses(nil)
+	if len(buf) == 0 {
 		return nil
 	}
-	var cl cClass
-	const sz = unsafe.Sizeof(cl)
-
-	buf := malloc(uintptr(n) * sz)
-	_ = objc_getClassList((*cClass)(buf), n)
-
-	out := make([]Class, 0, n)
-	for i := 0; i < n; i++ {
-		off := uintptr(i) * sz
-		c := (*cClass)(incPtr(buf, off))
-		out = append(out, Class{class: *c})
+	out := make([]Class, 0, len(buf))
+	for _, c := range buf {
+		out = append(out, Class{class: c})
 	}
-	free(buf)
 	return out
 }
 
@@ -63,8 +48,7 @@ func (c Class) Name() string {
 	if !c.Valid() {
 		return ""
 	}
-	cstr := class_getName(c.class)
-	return C.GoString(cstr)
+	return class_getName(c.class)
 }
 
 // GetSuperclass returns the superclass of a class.
@@ -1,24 +1,82 @@
+//go:build !objcfake
+// +build !objcfake
+
 package objc
 
 /*
 #cgo LDFLAGS: -lobjc
 #include <objc/runtime.h>
 #include <objc/message.h>
+
+// Not declared in public headers.
+void *objc_autoreleasePoolPush(void);
+void objc_autoreleasePoolPop(void *pool);
+id objc_retain(id obj);
+void objc_release(id obj);
+id objc_autorelease(id obj);
+id objc_initWeak(id *location, id val);
+id objc_loadWeakRetained(id *location);
+void objc_destroyWeak(id *location);
+
+// Declared in Block.h.
+void *_Block_copy(const void *block);
+void _Block_release(const void *block);
+extern void *_NSConcreteStackBlock[32];
+
+static volatile long go_image_gen;
+
+static void go_image_loaded(const struct mach_header *header) {
+	__sync_fetch_and_add(&go_image_gen, 1);
+}
+
+static void go_watch_images() {
+	objc_addLoadImageFunc(go_image_loaded);
+}
+
+static long go_image_generation() {
+	return __sync_fetch_and_add(&go_image_gen, 0);
+}
 */
 import "C"
 
-type cClass = C.Class
+import (
+	"sync"
+	"unsafe"
+)
 
-func objc_getClass(name *C.char) cClass {
-	return C.objc_getClass(name)
+type (
+	cClass  = C.Class
+	cMethod = C.Method
+	cSEL    = C.SEL
+	cIvar   = C.Ivar
+	cID     = C.id
+	cProp   = C.objc_property_t
+
+	cProtocol = C.Protocol
+)
+
+func objc_getClass(name string) cClass {
+	cstr := C.CString(name)
+	defer freeString(cstr)
+	return C.objc_getClass(cstr)
 }
 
-func objc_getClassList(buf *cClass, n int) int {
-	return int(C.objc_getClassList(buf, C.int(n)))
+// objc_getClassList fills the buffer with registered classes and returns the total number of classes.
+func objc_getClassList(buf []cClass) int {
+	var p *cClass
+	if len(buf) != 0 {
+		p = &buf[0]
+	}
+	return int(C.objc_getClassList(p, C.int(len(buf))))
 }
 
-func class_getName(c cClass) *C.char {
-	return C.class_getName(c)
+func class_getName(c cClass) string {
+	return C.GoString(C.class_getName(c))
+}
+
+// class_hasPrefix checks if the class name starts with a given prefix without copying the name.
+func class_hasPrefix(c cClass, prefix string) bool {
+	return hasPrefix(C.class_getName(c), prefix)
 }
 
 func class_getSuperclass(c cClass) cClass {
@@ -32,3 +90,400 @@ func class_isMetaClass(c cClass) bool {
 func class_getInstanceSize(c cClass) uintptr {
 	return uintptr(C.class_getInstanceSize(c))
 }
+
+func class_copyMethodList(c cClass) []cMethod {
+	var n C.uint
+	buf := C.class_copyMethodList(c, &n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]cMethod, n)
+	copy(out, (*[1 << 28]cMethod)(unsafe.Pointer(buf))[:n:n])
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func class_getInstanceMethod(c cClass, sel cSEL) cMethod {
+	return C.class_getInstanceMethod(c, sel)
+}
+
+func class_getClassMethod(c cClass, sel cSEL) cMethod {
+	return C.class_getClassMethod(c, sel)
+}
+
+func method_getName(m cMethod) cSEL {
+	return C.method_getName(m)
+}
+
+func method_getTypeEncoding(m cMethod) string {
+	return C.GoString(C.method_getTypeEncoding(m))
+}
+
+func method_getNumberOfArguments(m cMethod) int {
+	return int(C.method_getNumberOfArguments(m))
+}
+
+func method_getImplementation(m cMethod) unsafe.Pointer {
+	return unsafe.Pointer(C.method_getImplementation(m))
+}
+
+func sel_registerName(name string) cSEL {
+	cstr := C.CString(name)
+	defer freeString(cstr)
+	return C.sel_registerName(cstr)
+}
+
+func sel_getName(sel cSEL) string {
+	return C.GoString(C.sel_getName(sel))
+}
+
+func class_copyIvarList(c cClass) []cIvar {
+	var n C.uint
+	buf := C.class_copyIvarList(c, &n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]cIvar, n)
+	copy(out, (*[1 << 28]cIvar)(unsafe.Pointer(buf))[:n:n])
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func class_getInstanceVariable(c cClass, name string) cIvar {
+	cstr := C.CString(name)
+	defer freeString(cstr)
+	return C.class_getInstanceVariable(c, cstr)
+}
+
+func ivar_getName(v cIvar) string {
+	return C.GoString(C.ivar_getName(v))
+}
+
+func ivar_getOffset(v cIvar) uintptr {
+	return uintptr(C.ivar_getOffset(v))
+}
+
+func ivar_getTypeEncoding(v cIvar) string {
+	return C.GoString(C.ivar_getTypeEncoding(v))
+}
+
+func object_getClass(obj cID) cClass {
+	return C.object_getClass(obj)
+}
+
+func object_setClass(obj cID, c cClass) cClass {
+	return C.object_setClass(obj, c)
+}
+
+func object_getClassName(obj cID) string {
+	return C.GoString(C.object_getClassName(obj))
+}
+
+func object_getIndexedIvars(obj cID) unsafe.Pointer {
+	return C.object_getIndexedIvars(obj)
+}
+
+func class_createInstance(c cClass, extra uintptr) cID {
+	return C.class_createInstance(c, C.size_t(extra))
+}
+
+func object_copy(obj cID, extra uintptr) cID {
+	return C.object_copy(obj, C.size_t(extra))
+}
+
+func object_dispose(obj cID) {
+	C.object_dispose(obj)
+}
+
+func class_copyPropertyList(c cClass) []cProp {
+	var n C.uint
+	buf := C.class_copyPropertyList(c, &n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]cProp, n)
+	copy(out, (*[1 << 28]cProp)(unsafe.Pointer(buf))[:n:n])
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func class_getProperty(c cClass, name string) cProp {
+	cstr := C.CString(name)
+	defer freeString(cstr)
+	return C.class_getProperty(c, cstr)
+}
+
+func property_getName(p cProp) string {
+	return C.GoString(C.property_getName(p))
+}
+
+func property_getAttributes(p cProp) string {
+	return C.GoString(C.property_getAttributes(p))
+}
+
+func cBool(v bool) C.BOOL {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func objc_getProtocol(name string) *cProtocol {
+	cstr := C.CString(name)
+	defer freeString(cstr)
+	return C.objc_getProtocol(cstr)
+}
+
+func objc_copyProtocolList() []*cProtocol {
+	var n C.uint
+	buf := C.objc_copyProtocolList(&n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]*cProtocol, n)
+	copy(out, (*[1 << 28]*cProtocol)(unsafe.Pointer(buf))[:n:n])
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func class_copyProtocolList(c cClass) []*cProtocol {
+	var n C.uint
+	buf := C.class_copyProtocolList(c, &n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]*cProtocol, n)
+	copy(out, (*[1 << 28]*cProtocol)(unsafe.Pointer(buf))[:n:n])
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func class_conformsToProtocol(c cClass, p *cProtocol) bool {
+	return C.class_conformsToProtocol(c, p) != 0
+}
+
+func protocol_getName(p *cProtocol) string {
+	return C.GoString(C.protocol_getName(p))
+}
+
+func protocol_isEqual(p1, p2 *cProtocol) bool {
+	return C.protocol_isEqual(p1, p2) != 0
+}
+
+func protocol_conformsToProtocol(p1, p2 *cProtocol) bool {
+	return C.protocol_conformsToProtocol(p1, p2) != 0
+}
+
+func protocol_copyProtocolList(p *cProtocol) []*cProtocol {
+	var n C.uint
+	buf := C.protocol_copyProtocolList(p, &n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]*cProtocol, n)
+	copy(out, (*[1 << 28]*cProtocol)(unsafe.Pointer(buf))[:n:n])
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func protocol_copyMethodDescriptionList(p *cProtocol, required, instance bool) []MethodDescription {
+	var n C.uint
+	buf := C.protocol_copyMethodDescriptionList(p, cBool(required), cBool(instance), &n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]MethodDescription, 0, n)
+	for _, d := range (*[1 << 28]C.struct_objc_method_description)(unsafe.Pointer(buf))[:n:n] {
+		var name string
+		if d.name != nil {
+			name = sel_getName(d.name)
+		}
+		out = append(out, MethodDescription{Name: name, Types: C.GoString(d.types)})
+	}
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func sel_isEqual(s1, s2 cSEL) bool {
+	return C.sel_isEqual(s1, s2) != 0
+}
+
+// sel_registerTypedName registers a selector with a given type encoding.
+// Apple runtime does not support typed selectors, thus it always returns false.
+func sel_registerTypedName(name, types string) (cSEL, bool) {
+	return nil, false
+}
+
+// sel_getType returns the type encoding of a typed selector.
+// Apple runtime does not support typed selectors, thus it always returns an empty string.
+func sel_getType(sel cSEL) string {
+	return ""
+}
+
+// msgLookup returns a function that should be called to send a message to the object.
+//
+// Apple runtime dispatches all messages via objc_msgSend, thus it returns it directly.
+func msgLookup(obj cID, sel cSEL) unsafe.Pointer {
+	return unsafe.Pointer(C.objc_msgSend)
+}
+
+func objc_allocateClassPair(super cClass, name string) cClass {
+	cstr := C.CString(name)
+	defer freeString(cstr)
+	return C.objc_allocateClassPair(super, cstr, 0)
+}
+
+func objc_registerClassPair(c cClass) {
+	C.objc_registerClassPair(c)
+}
+
+func objc_disposeClassPair(c cClass) {
+	C.objc_disposeClassPair(c)
+}
+
+func class_addMethod(c cClass, sel cSEL, imp unsafe.Pointer, types string) bool {
+	ctypes := C.CString(types)
+	defer freeString(ctypes)
+	return C.class_addMethod(c, sel, C.IMP(imp), ctypes) != 0
+}
+
+func class_addIvar(c cClass, name string, size uintptr, align uint8, types string) bool {
+	cname := C.CString(name)
+	defer freeString(cname)
+	ctypes := C.CString(types)
+	defer freeString(ctypes)
+	return C.class_addIvar(c, cname, C.size_t(size), C.uint8_t(align), ctypes) != 0
+}
+
+func class_addProtocol(c cClass, p *cProtocol) bool {
+	return C.class_addProtocol(c, p) != 0
+}
+
+func class_getMethodImplementation(c cClass, sel cSEL) unsafe.Pointer {
+	return unsafe.Pointer(C.class_getMethodImplementation(c, sel))
+}
+
+func class_replaceMethod(c cClass, sel cSEL, imp unsafe.Pointer, types string) unsafe.Pointer {
+	ctypes := C.CString(types)
+	defer freeString(ctypes)
+	return unsafe.Pointer(C.class_replaceMethod(c, sel, C.IMP(imp), ctypes))
+}
+
+func method_setImplementation(m cMethod, imp unsafe.Pointer) unsafe.Pointer {
+	return unsafe.Pointer(C.method_setImplementation(m, C.IMP(imp)))
+}
+
+func method_exchangeImplementations(m1, m2 cMethod) {
+	C.method_exchangeImplementations(m1, m2)
+}
+
+func objc_setAssociatedObject(obj cID, key unsafe.Pointer, val cID, policy uintptr) bool {
+	C.objc_setAssociatedObject(obj, key, val, C.objc_AssociationPolicy(policy))
+	return true
+}
+
+func objc_getAssociatedObject(obj cID, key unsafe.Pointer) cID {
+	return C.objc_getAssociatedObject(obj, key)
+}
+
+func objc_removeAssociatedObjects(obj cID) {
+	C.objc_removeAssociatedObjects(obj)
+}
+
+func objc_autoreleasePoolPush() unsafe.Pointer {
+	return C.objc_autoreleasePoolPush()
+}
+
+func objc_autoreleasePoolPop(pool unsafe.Pointer) {
+	C.objc_autoreleasePoolPop(pool)
+}
+
+func objc_retain(obj cID) bool {
+	C.objc_retain(obj)
+	return true
+}
+
+func objc_release(obj cID) bool {
+	C.objc_release(obj)
+	return true
+}
+
+func objc_autorelease(obj cID) bool {
+	C.objc_autorelease(obj)
+	return true
+}
+
+func _Block_copy(b unsafe.Pointer) unsafe.Pointer {
+	return C._Block_copy(b)
+}
+
+func _Block_release(b unsafe.Pointer) {
+	C._Block_release(b)
+}
+
+// blockStackClass returns the class of blocks allocated on the stack.
+func blockStackClass() unsafe.Pointer {
+	return unsafe.Pointer(&C._NSConcreteStackBlock)
+}
+
+func objc_initWeak(loc *cID, obj cID) bool {
+	C.objc_initWeak(loc, obj)
+	return true
+}
+
+func objc_storeWeak(loc *cID, obj cID) {
+	C.objc_storeWeak(loc, obj)
+}
+
+func objc_loadWeakRetained(loc *cID) cID {
+	return C.objc_loadWeakRetained(loc)
+}
+
+func objc_destroyWeak(loc *cID) {
+	C.objc_destroyWeak(loc)
+}
+
+func class_getImageName(c cClass) string {
+	return C.GoString(C.class_getImageName(c))
+}
+
+func objc_copyImageNames() []string {
+	var n C.uint
+	buf := C.objc_copyImageNames(&n)
+	return goStrings(buf, n)
+}
+
+func objc_copyClassNamesForImage(image string) []string {
+	cstr := C.CString(image)
+	defer freeString(cstr)
+	var n C.uint
+	buf := C.objc_copyClassNamesForImage(cstr, &n)
+	return goStrings(buf, n)
+}
+
+var watchImages sync.Once
+
+// imageGeneration returns a counter that is incremented each time a new image is loaded.
+//
+// See objc_addLoadImageFunc.
+func imageGeneration() (uint64, bool) {
+	watchImages.Do(func() {
+		C.go_watch_images()
+	})
+	return uint64(C.go_image_generation()), true
+}
+
+// runtimeInfo returns the runtime flavour and its capabilities.
+// Apple runtime on all supported architectures uses the modern ABI.
+func runtimeInfo() RuntimeInfo {
+	info := RuntimeInfo{
+		Flavor: FlavorApple,
+		ABI:    2,
+		Capabilities: CapNonFragileIvars | CapBlocks | CapARC | CapWeak |
+			CapAssociatedObjects | CapAutoreleasePools | CapImages | CapForwarding,
+	}
+	if catchesExceptions() {
+		info.Capabilities |= CapExceptions
+	}
+	return info
+}
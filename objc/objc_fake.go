@@ -0,0 +1,587 @@
+//go:build objcfake
+// +build objcfake
+
+package objc
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/dennwc/go-apple/objc/encoding"
+)
+
+// This file implements a fake Objective-C runtime in Go memory. It is used instead of libobjc
+// when the package is built with the objcfake tag, and doesn't require cgo:
+//
+//	CGO_ENABLED=0 go test -tags objcfake ./...
+//
+// The fake runtime supports classes, metaclasses, selectors, methods, instance variables,
+// messaging with forwarding, reference counting, weak references and associated objects. Root classes
+// Object and NSObject are predefined, see root_fake.go. Protocols and properties can't be
+// declared, and blocks, autorelease pools and images are not supported.
+
+type (
+	cClass    = *fakeClass
+	cMethod   = *fakeMethod
+	cSEL      = *fakeSel
+	cIvar     = *fakeIvar
+	cID       = *fakeObject
+	cProp     = *fakeProperty
+	cProtocol = fakeProtocol
+)
+
+// fakeObject is a header of all objects, including classes.
+type fakeObject struct {
+	isa *fakeClass
+}
+
+// fakeClass is a class or a metaclass. It can be used as an object, since it starts with the isa pointer.
+type fakeClass struct {
+	isa        *fakeClass
+	super      *fakeClass
+	name       string
+	meta       bool
+	registered bool
+	size       uintptr // instance size
+	methods    []*fakeMethod
+	ivars      []*fakeIvar
+	protocols  []*fakeProtocol
+}
+
+type fakeSel struct {
+	name string
+}
+
+type fakeMethod struct {
+	sel   *fakeSel
+	types string
+	imp   unsafe.Pointer
+}
+
+type fakeIvar struct {
+	name   string
+	types  string
+	offset uintptr
+}
+
+type fakeProperty struct {
+	name  string
+	attrs string
+}
+
+type fakeProtocol struct {
+	name      string
+	protocols []*fakeProtocol
+	methods   map[[2]bool][]MethodDescription // by required and instance flags
+}
+
+// fakeRuntime holds the state of the fake runtime.
+//
+// The lock must not be held while calling method implementations, since they may call back into the runtime.
+var fakeRuntime = struct {
+	sync.RWMutex
+	classes   []*fakeClass          // registered classes in the registration order
+	byName    map[string]*fakeClass // registered and allocated classes
+	sels      map[string]*fakeSel
+	protocols map[string]*fakeProtocol
+}{
+	byName:    make(map[string]*fakeClass),
+	sels:      make(map[string]*fakeSel),
+	protocols: make(map[string]*fakeProtocol),
+}
+
+func objc_getClass(name string) cClass {
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	c := fakeRuntime.byName[name]
+	if c == nil || !c.registered {
+		return nil
+	}
+	return c
+}
+
+// objc_getClassList fills the buffer with registered classes and returns the total number of classes.
+func objc_getClassList(buf []cClass) int {
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	copy(buf, fakeRuntime.classes)
+	return len(fakeRuntime.classes)
+}
+
+func class_getName(c cClass) string {
+	return c.name
+}
+
+// class_hasPrefix checks if the class name starts with a given prefix.
+func class_hasPrefix(c cClass, prefix string) bool {
+	return strings.HasPrefix(c.name, prefix)
+}
+
+func class_getSuperclass(c cClass) cClass {
+	return c.super
+}
+
+func class_isMetaClass(c cClass) bool {
+	return c.meta
+}
+
+func class_getInstanceSize(c cClass) uintptr {
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	return c.size
+}
+
+func class_copyMethodList(c cClass) []cMethod {
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	if len(c.methods) == 0 {
+		return nil
+	}
+	return append([]cMethod(nil), c.methods...)
+}
+
+// ownMethod returns a method implemented by the class itself. The lock must be held.
+func (c *fakeClass) ownMethod(sel cSEL) cMethod {
+	for _, m := range c.methods {
+		if m.sel == sel {
+			return m
+		}
+	}
+	return nil
+}
+
+// lookupMethod returns a method implemented by the class or its superclasses. The lock must be held.
+func (c *fakeClass) lookupMethod(sel cSEL) cMethod {
+	for ; c != nil; c = c.super {
+		if m := c.ownMethod(sel); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// class_getInstanceMethod returns an instance method of the class. Same as the real runtimes,
+// it calls +resolveInstanceMethod: if the class doesn't implement the method.
+func class_getInstanceMethod(c cClass, sel cSEL) cMethod {
+	if m := fakeLookup(c, sel); m != nil {
+		return m
+	}
+	if fakeResolve(c, sel) {
+		return fakeLookup(c, sel)
+	}
+	return nil
+}
+
+// fakeLookup returns a method implemented by the class or its superclasses.
+func fakeLookup(c cClass, sel cSEL) cMethod {
+	if c == nil || sel == nil {
+		return nil
+	}
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	return c.lookupMethod(sel)
+}
+
+func class_getClassMethod(c cClass, sel cSEL) cMethod {
+	if c == nil {
+		return nil
+	}
+	return class_getInstanceMethod(c.isa, sel)
+}
+
+func method_getName(m cMethod) cSEL {
+	return m.sel
+}
+
+func method_getTypeEncoding(m cMethod) string {
+	return m.types
+}
+
+func method_getNumberOfArguments(m cMethod) int {
+	sig, err := encoding.ParseMethod(m.types)
+	if err != nil {
+		return 0
+	}
+	return len(sig.Args)
+}
+
+func method_getImplementation(m cMethod) unsafe.Pointer {
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	return m.imp
+}
+
+func sel_registerName(name string) cSEL {
+	fakeRuntime.Lock()
+	defer fakeRuntime.Unlock()
+	s := fakeRuntime.sels[name]
+	if s == nil {
+		s = &fakeSel{name: name}
+		fakeRuntime.sels[name] = s
+	}
+	return s
+}
+
+func sel_getName(sel cSEL) string {
+	return sel.name
+}
+
+func class_copyIvarList(c cClass) []cIvar {
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	if len(c.ivars) == 0 {
+		return nil
+	}
+	return append([]cIvar(nil), c.ivars...)
+}
+
+func class_getInstanceVariable(c cClass, name string) cIvar {
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	for ; c != nil; c = c.super {
+		for _, v := range c.ivars {
+			if v.name == name {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+func ivar_getName(v cIvar) string {
+	return v.name
+}
+
+func ivar_getOffset(v cIvar) uintptr {
+	return v.offset
+}
+
+func ivar_getTypeEncoding(v cIvar) string {
+	return v.types
+}
+
+func object_getClass(obj cID) cClass {
+	if obj == nil {
+		return nil
+	}
+	return obj.isa
+}
+
+// class_copyPropertyList always returns nil, since properties can't be declared in the fake runtime.
+func class_copyPropertyList(c cClass) []cProp {
+	return nil
+}
+
+func class_getProperty(c cClass, name string) cProp {
+	return nil
+}
+
+func property_getName(p cProp) string {
+	return p.name
+}
+
+func property_getAttributes(p cProp) string {
+	return p.attrs
+}
+
+func objc_getProtocol(name string) *cProtocol {
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	return fakeRuntime.protocols[name]
+}
+
+func objc_copyProtocolList() []*cProtocol {
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	if len(fakeRuntime.protocols) == 0 {
+		return nil
+	}
+	out := make([]*cProtocol, 0, len(fakeRuntime.protocols))
+	for _, p := range fakeRuntime.protocols {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].name < out[j].name
+	})
+	return out
+}
+
+func class_copyProtocolList(c cClass) []*cProtocol {
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	if len(c.protocols) == 0 {
+		return nil
+	}
+	return append([]*cProtocol(nil), c.protocols...)
+}
+
+func class_conformsToProtocol(c cClass, p *cProtocol) bool {
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	for _, p2 := range c.protocols {
+		if protocol_conformsToProtocol(p2, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func protocol_getName(p *cProtocol) string {
+	return p.name
+}
+
+func protocol_isEqual(p1, p2 *cProtocol) bool {
+	return p1 == p2
+}
+
+func protocol_conformsToProtocol(p1, p2 *cProtocol) bool {
+	if p1 == p2 {
+		return true
+	}
+	for _, p := range p1.protocols {
+		if protocol_conformsToProtocol(p, p2) {
+			return true
+		}
+	}
+	return false
+}
+
+func protocol_copyProtocolList(p *cProtocol) []*cProtocol {
+	if len(p.protocols) == 0 {
+		return nil
+	}
+	return append([]*cProtocol(nil), p.protocols...)
+}
+
+func protocol_copyMethodDescriptionList(p *cProtocol, required, instance bool) []MethodDescription {
+	list := p.methods[[2]bool{required, instance}]
+	if len(list) == 0 {
+		return nil
+	}
+	return append([]MethodDescription(nil), list...)
+}
+
+func sel_isEqual(s1, s2 cSEL) bool {
+	return s1 == s2
+}
+
+// sel_registerTypedName registers a selector with a given type encoding.
+// The fake runtime does not support typed selectors, thus it always returns false.
+func sel_registerTypedName(name, types string) (cSEL, bool) {
+	return nil, false
+}
+
+// sel_getType returns the type encoding of a typed selector.
+// The fake runtime does not support typed selectors, thus it always returns an empty string.
+func sel_getType(sel cSEL) string {
+	return ""
+}
+
+// msgLookup returns a function that should be called to send a message to the object.
+// Methods are resolved and forwarded the same way as in the real runtimes, see forward_fake.go.
+// It returns nil if the object doesn't implement the method and doesn't forward messages.
+func msgLookup(obj cID, sel cSEL) unsafe.Pointer {
+	c := object_getClass(obj)
+	if imp := class_getMethodImplementation(c, sel); imp != nil {
+		return imp
+	}
+	if fakeResolve(c, sel) {
+		if imp := class_getMethodImplementation(c, sel); imp != nil {
+			return imp
+		}
+	}
+	if fakeForwards(c) {
+		return unsafe.Pointer(fakeForwardIMP)
+	}
+	return nil
+}
+
+func objc_allocateClassPair(super cClass, name string) cClass {
+	fakeRuntime.Lock()
+	defer fakeRuntime.Unlock()
+	if name == "" || fakeRuntime.byName[name] != nil {
+		return nil
+	}
+	meta := &fakeClass{name: name, meta: true}
+	// root classes must declare the isa ivar themselves
+	c := &fakeClass{isa: meta, super: super, name: name}
+	if super != nil {
+		// metaclasses of all classes are instances of the root metaclass
+		meta.isa = super.isa.isa
+		meta.super = super.isa
+		c.size = super.size
+	} else {
+		meta.isa = meta
+		meta.super = c
+	}
+	fakeRuntime.byName[name] = c
+	return c
+}
+
+func objc_registerClassPair(c cClass) {
+	fakeRuntime.Lock()
+	defer fakeRuntime.Unlock()
+	if c.registered {
+		return
+	}
+	c.registered = true
+	c.isa.registered = true
+	fakeRuntime.classes = append(fakeRuntime.classes, c)
+}
+
+func objc_disposeClassPair(c cClass) {
+	fakeRuntime.Lock()
+	defer fakeRuntime.Unlock()
+	if fakeRuntime.byName[c.name] == c {
+		delete(fakeRuntime.byName, c.name)
+	}
+	for i, c2 := range fakeRuntime.classes {
+		if c2 == c {
+			fakeRuntime.classes = append(fakeRuntime.classes[:i], fakeRuntime.classes[i+1:]...)
+			break
+		}
+	}
+	c.registered = false
+	c.isa.registered = false
+}
+
+func class_addMethod(c cClass, sel cSEL, imp unsafe.Pointer, types string) bool {
+	fakeRuntime.Lock()
+	defer fakeRuntime.Unlock()
+	if c.ownMethod(sel) != nil {
+		return false
+	}
+	c.methods = append(c.methods, &fakeMethod{sel: sel, types: types, imp: imp})
+	return true
+}
+
+func class_addIvar(c cClass, name string, size uintptr, align uint8, types string) bool {
+	fakeRuntime.Lock()
+	defer fakeRuntime.Unlock()
+	if c.registered || c.meta {
+		return false
+	}
+	for _, v := range c.ivars {
+		if v.name == name {
+			return false
+		}
+	}
+	a := uintptr(1) << align
+	off := (c.size + a - 1) &^ (a - 1)
+	c.ivars = append(c.ivars, &fakeIvar{name: name, types: types, offset: off})
+	c.size = off + size
+	return true
+}
+
+func class_addProtocol(c cClass, p *cProtocol) bool {
+	fakeRuntime.Lock()
+	defer fakeRuntime.Unlock()
+	for _, p2 := range c.protocols {
+		if p2 == p {
+			return false
+		}
+	}
+	c.protocols = append(c.protocols, p)
+	return true
+}
+
+// class_getMethodImplementation returns an implementation of the method.
+// Unlike the real runtimes, it returns nil if the class doesn't implement the method.
+func class_getMethodImplementation(c cClass, sel cSEL) unsafe.Pointer {
+	if c == nil || sel == nil {
+		return nil
+	}
+	fakeRuntime.RLock()
+	defer fakeRuntime.RUnlock()
+	if m := c.lookupMethod(sel); m != nil {
+		return m.imp
+	}
+	return nil
+}
+
+func class_replaceMethod(c cClass, sel cSEL, imp unsafe.Pointer, types string) unsafe.Pointer {
+	fakeRuntime.Lock()
+	defer fakeRuntime.Unlock()
+	if m := c.ownMethod(sel); m != nil {
+		prev := m.imp
+		m.imp = imp
+		return prev
+	}
+	c.methods = append(c.methods, &fakeMethod{sel: sel, types: types, imp: imp})
+	return nil
+}
+
+func method_setImplementation(m cMethod, imp unsafe.Pointer) unsafe.Pointer {
+	fakeRuntime.Lock()
+	defer fakeRuntime.Unlock()
+	prev := m.imp
+	m.imp = imp
+	return prev
+}
+
+func method_exchangeImplementations(m1, m2 cMethod) {
+	fakeRuntime.Lock()
+	defer fakeRuntime.Unlock()
+	m1.imp, m2.imp = m2.imp, m1.imp
+}
+
+// objc_retain always returns false. Objects are retained by sending the retain message instead.
+func objc_retain(obj cID) bool {
+	return false
+}
+
+// objc_release always returns false. Objects are released by sending the release message instead.
+func objc_release(obj cID) bool {
+	return false
+}
+
+// objc_autorelease always returns false. Objects are autoreleased by sending the autorelease message instead.
+func objc_autorelease(obj cID) bool {
+	return false
+}
+
+// objc_autoreleasePoolPush returns a new pool token. The fake runtime doesn't autorelease objects,
+// thus pools only exist to check that they are used on the right threads.
+func objc_autoreleasePoolPush() unsafe.Pointer {
+	if !fakeThreads {
+		return nil
+	}
+	return malloc(1)
+}
+
+func objc_autoreleasePoolPop(pool unsafe.Pointer) {
+	free(pool)
+}
+
+func class_getImageName(c cClass) string {
+	return ""
+}
+
+func objc_copyImageNames() []string {
+	return nil
+}
+
+func objc_copyClassNamesForImage(image string) []string {
+	return nil
+}
+
+// imageGeneration returns a counter that is incremented each time a new image is loaded.
+// The fake runtime has no images, thus it's always zero.
+func imageGeneration() (uint64, bool) {
+	return 0, true
+}
+
+// runtimeInfo returns the runtime flavour and its capabilities.
+func runtimeInfo() RuntimeInfo {
+	caps := CapNonFragileIvars | CapWeak | CapAssociatedObjects | CapForwarding | CapExceptions
+	if fakeThreads {
+		caps |= CapAutoreleasePools
+	}
+	return RuntimeInfo{
+		Flavor:       FlavorFake,
+		ABI:          2,
+		Capabilities: caps,
+	}
+}
@@ -1,25 +1,271 @@
+//go:build !objcfake
+// +build !objcfake
+
 package objc
 
 /*
-#cgo LDFLAGS: -Wl,--no-as-needed -lobjc
+#cgo LDFLAGS: -Wl,--no-as-needed -lobjc -ldl
+#define _GNU_SOURCE
 #define __OBJC2__ 1
+#include <dlfcn.h>
+#include <stdint.h>
 #include <objc/runtime.h>
 #include <objc/message.h>
+
+// Entry points that are named differently in GCC libobjc and GNUstep libobjc2.
+static SEL (*go_sel_registerTypedName)(const char *name, const char *types);
+static const char *(*go_sel_getType)(SEL sel);
+
+// Entry points that are only provided by GNUstep libobjc2.
+static void (*go_objc_setAssociatedObject)(id obj, const void *key, id value, uintptr_t policy);
+static id (*go_objc_getAssociatedObject)(id obj, const void *key);
+static void (*go_objc_removeAssociatedObjects)(id obj);
+static void *(*go_objc_autoreleasePoolPush)(void);
+static void (*go_objc_autoreleasePoolPop)(void *pool);
+static id (*go_objc_retain)(id obj);
+static void (*go_objc_release)(id obj);
+static id (*go_objc_autorelease)(id obj);
+static void *(*go_Block_copy)(const void *block);
+static void (*go_Block_release)(const void *block);
+static void *go_NSConcreteStackBlock;
+static id (*go_objc_initWeak)(id *loc, id obj);
+static id (*go_objc_storeWeak)(id *loc, id obj);
+static id (*go_objc_loadWeakRetained)(id *loc);
+static void (*go_objc_destroyWeak)(id *loc);
+static const char *(*go_class_getImageName)(Class c);
+static const char **(*go_objc_copyImageNames)(unsigned int *n);
+static const char **(*go_objc_copyClassNamesForImage)(const char *image, unsigned int *n);
+
+static void go_objc_init() {
+	go_sel_registerTypedName = dlsym(RTLD_DEFAULT, "sel_registerTypedName_np");
+	if (!go_sel_registerTypedName) {
+		go_sel_registerTypedName = dlsym(RTLD_DEFAULT, "sel_registerTypedName");
+	}
+	go_sel_getType = dlsym(RTLD_DEFAULT, "sel_getType_np");
+	if (!go_sel_getType) {
+		go_sel_getType = dlsym(RTLD_DEFAULT, "sel_getTypeEncoding");
+	}
+	go_objc_setAssociatedObject = dlsym(RTLD_DEFAULT, "objc_setAssociatedObject");
+	go_objc_getAssociatedObject = dlsym(RTLD_DEFAULT, "objc_getAssociatedObject");
+	go_objc_removeAssociatedObjects = dlsym(RTLD_DEFAULT, "objc_removeAssociatedObjects");
+	go_objc_autoreleasePoolPush = dlsym(RTLD_DEFAULT, "objc_autoreleasePoolPush");
+	go_objc_autoreleasePoolPop = dlsym(RTLD_DEFAULT, "objc_autoreleasePoolPop");
+	go_objc_retain = dlsym(RTLD_DEFAULT, "objc_retain");
+	go_objc_release = dlsym(RTLD_DEFAULT, "objc_release");
+	go_objc_autorelease = dlsym(RTLD_DEFAULT, "objc_autorelease");
+	go_Block_copy = dlsym(RTLD_DEFAULT, "_Block_copy");
+	go_Block_release = dlsym(RTLD_DEFAULT, "_Block_release");
+	go_NSConcreteStackBlock = dlsym(RTLD_DEFAULT, "_NSConcreteStackBlock");
+	go_objc_initWeak = dlsym(RTLD_DEFAULT, "objc_initWeak");
+	go_objc_storeWeak = dlsym(RTLD_DEFAULT, "objc_storeWeak");
+	go_objc_loadWeakRetained = dlsym(RTLD_DEFAULT, "objc_loadWeakRetained");
+	go_objc_destroyWeak = dlsym(RTLD_DEFAULT, "objc_destroyWeak");
+	go_class_getImageName = dlsym(RTLD_DEFAULT, "class_getImageName");
+	go_objc_copyImageNames = dlsym(RTLD_DEFAULT, "objc_copyImageNames");
+	go_objc_copyClassNamesForImage = dlsym(RTLD_DEFAULT, "objc_copyClassNamesForImage");
+}
+
+static SEL go_sel_registerTypedName_call(const char *name, const char *types) {
+	return go_sel_registerTypedName(name, types);
+}
+
+static const char *go_sel_getType_call(SEL sel) {
+	return go_sel_getType(sel);
+}
+
+static int go_objc_setAssociatedObject_call(id obj, const void *key, id value, uintptr_t policy) {
+	if (!go_objc_setAssociatedObject) {
+		return 0;
+	}
+	go_objc_setAssociatedObject(obj, key, value, policy);
+	return 1;
+}
+
+static id go_objc_getAssociatedObject_call(id obj, const void *key) {
+	if (!go_objc_getAssociatedObject) {
+		return NULL;
+	}
+	return go_objc_getAssociatedObject(obj, key);
+}
+
+static void go_objc_removeAssociatedObjects_call(id obj) {
+	if (go_objc_removeAssociatedObjects) {
+		go_objc_removeAssociatedObjects(obj);
+	}
+}
+
+static void *go_objc_autoreleasePoolPush_call() {
+	if (!go_objc_autoreleasePoolPush) {
+		return NULL;
+	}
+	return go_objc_autoreleasePoolPush();
+}
+
+static void go_objc_autoreleasePoolPop_call(void *pool) {
+	if (go_objc_autoreleasePoolPop) {
+		go_objc_autoreleasePoolPop(pool);
+	}
+}
+
+static int go_objc_retain_call(id obj) {
+	if (!go_objc_retain) {
+		return 0;
+	}
+	go_objc_retain(obj);
+	return 1;
+}
+
+static int go_objc_release_call(id obj) {
+	if (!go_objc_release) {
+		return 0;
+	}
+	go_objc_release(obj);
+	return 1;
+}
+
+static int go_objc_autorelease_call(id obj) {
+	if (!go_objc_autorelease) {
+		return 0;
+	}
+	go_objc_autorelease(obj);
+	return 1;
+}
+
+static void *go_Block_copy_call(const void *block) {
+	if (!go_Block_copy || !go_Block_release || !go_NSConcreteStackBlock) {
+		return NULL;
+	}
+	return go_Block_copy(block);
+}
+
+static void go_Block_release_call(const void *block) {
+	if (go_Block_release) {
+		go_Block_release(block);
+	}
+}
+
+// Runtime features, see runtimeInfo.
+struct go_objc_features {
+	int gnustep;
+	int gcc;
+	int abi2;
+	int nonfragile;
+	int blocks;
+	int arc;
+	int weak;
+	int typed;
+	int assoc;
+	int pools;
+	int images;
+};
+
+static struct go_objc_features go_objc_features() {
+	struct go_objc_features f = {0};
+	int (*test_capability)(int) = dlsym(RTLD_DEFAULT, "objc_test_capability");
+	f.gnustep = test_capability != NULL || dlsym(RTLD_DEFAULT, "sel_registerTypedName_np") != NULL;
+	f.gcc = !f.gnustep && dlsym(RTLD_DEFAULT, "__objc_exec_class") != NULL;
+	// __objc_load is the loader entry point of the GNUstep 2.0 ABI
+	f.abi2 = f.gnustep && dlsym(RTLD_DEFAULT, "__objc_load") != NULL;
+	// 5 is OBJC_CAP_NONFRAGILE_IVARS
+	f.nonfragile = test_capability != NULL && test_capability(5);
+	f.blocks = go_Block_copy && go_Block_release && go_NSConcreteStackBlock;
+	f.arc = go_objc_retain && go_objc_release && go_objc_autorelease;
+	f.weak = go_objc_initWeak && go_objc_storeWeak && go_objc_loadWeakRetained && go_objc_destroyWeak;
+	f.typed = go_sel_registerTypedName && go_sel_getType;
+	f.assoc = go_objc_setAssociatedObject && go_objc_getAssociatedObject;
+	f.pools = go_objc_autoreleasePoolPush && go_objc_autoreleasePoolPop;
+	f.images = go_class_getImageName && go_objc_copyImageNames && go_objc_copyClassNamesForImage;
+	return f;
+}
+
+static void *go_block_stack_class() {
+	return go_NSConcreteStackBlock;
+}
+
+static int go_objc_initWeak_call(id *loc, id obj) {
+	if (!go_objc_initWeak || !go_objc_storeWeak || !go_objc_loadWeakRetained || !go_objc_destroyWeak) {
+		return 0;
+	}
+	go_objc_initWeak(loc, obj);
+	return 1;
+}
+
+static void go_objc_storeWeak_call(id *loc, id obj) {
+	go_objc_storeWeak(loc, obj);
+}
+
+static id go_objc_loadWeakRetained_call(id *loc) {
+	return go_objc_loadWeakRetained(loc);
+}
+
+static void go_objc_destroyWeak_call(id *loc) {
+	go_objc_destroyWeak(loc);
+}
+
+static const char *go_class_getImageName_call(Class c) {
+	if (!go_class_getImageName) {
+		return NULL;
+	}
+	return go_class_getImageName(c);
+}
+
+static const char **go_objc_copyImageNames_call(unsigned int *n) {
+	*n = 0;
+	if (!go_objc_copyImageNames) {
+		return NULL;
+	}
+	return go_objc_copyImageNames(n);
+}
+
+static const char **go_objc_copyClassNamesForImage_call(const char *image, unsigned int *n) {
+	*n = 0;
+	if (!go_objc_copyClassNamesForImage) {
+		return NULL;
+	}
+	return go_objc_copyClassNamesForImage(image, n);
+}
 */
 import "C"
 
-type cClass = C.Class
+import "unsafe"
 
-func objc_getClass(name *C.char) cClass {
-	return C.objc_getClass(name)
+type (
+	cClass  = C.Class
+	cMethod = C.Method
+	cSEL    = C.SEL
+	cIvar   = C.Ivar
+	cID     = C.id
+	cProp   = C.objc_property_t
+
+	cProtocol = C.Protocol
+)
+
+func init() {
+	C.go_objc_init()
+}
+
+func objc_getClass(name string) cClass {
+	cstr := C.CString(name)
+	defer freeString(cstr)
+	return C.objc_getClass(cstr)
+}
+
+// objc_getClassList fills the buffer with registered classes and returns the total number of classes.
+func objc_getClassList(buf []cClass) int {
+	var p *cClass
+	if len(buf) != 0 {
+		p = &buf[0]
+	}
+	return int(C.objc_getClassList(p, C.int(len(buf))))
 }
 
-func objc_getClassList(buf *cClass, n int) int {
-	return int(C.objc_getClassList(buf, C.int(n)))
+func class_getName(c cClass) string {
+	return C.GoString(C.class_getName(c))
 }
 
-func class_getName(c cClass) *C.char {
-	return C.class_getName(c)
+// class_hasPrefix checks if the class name starts with a given prefix without copying the name.
+func class_hasPrefix(c cClass, prefix string) bool {
+	return hasPrefix(C.class_getName(c), prefix)
 }
 
 func class_getSuperclass(c cClass) cClass {
@@ -33,3 +279,428 @@ func class_isMetaClass(c cClass) bool {
 func class_getInstanceSize(c cClass) uintptr {
 	return uintptr(C.class_getInstanceSize(c))
 }
+
+func class_copyMethodList(c cClass) []cMethod {
+	var n C.uint
+	buf := C.class_copyMethodList(c, &n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]cMethod, n)
+	copy(out, (*[1 << 28]cMethod)(unsafe.Pointer(buf))[:n:n])
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func class_getInstanceMethod(c cClass, sel cSEL) cMethod {
+	return C.class_getInstanceMethod(c, sel)
+}
+
+func class_getClassMethod(c cClass, sel cSEL) cMethod {
+	return C.class_getClassMethod(c, sel)
+}
+
+func method_getName(m cMethod) cSEL {
+	return C.method_getName(m)
+}
+
+func method_getTypeEncoding(m cMethod) string {
+	return C.GoString(C.method_getTypeEncoding(m))
+}
+
+func method_getNumberOfArguments(m cMethod) int {
+	return int(C.method_getNumberOfArguments(m))
+}
+
+func method_getImplementation(m cMethod) unsafe.Pointer {
+	return unsafe.Pointer(C.method_getImplementation(m))
+}
+
+func sel_registerName(name string) cSEL {
+	cstr := C.CString(name)
+	defer freeString(cstr)
+	return C.sel_registerName(cstr)
+}
+
+func sel_getName(sel cSEL) string {
+	return C.GoString(C.sel_getName(sel))
+}
+
+func class_copyIvarList(c cClass) []cIvar {
+	var n C.uint
+	buf := C.class_copyIvarList(c, &n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]cIvar, n)
+	copy(out, (*[1 << 28]cIvar)(unsafe.Pointer(buf))[:n:n])
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func class_getInstanceVariable(c cClass, name string) cIvar {
+	cstr := C.CString(name)
+	defer freeString(cstr)
+	return C.class_getInstanceVariable(c, cstr)
+}
+
+func ivar_getName(v cIvar) string {
+	return C.GoString(C.ivar_getName(v))
+}
+
+func ivar_getOffset(v cIvar) uintptr {
+	return uintptr(C.ivar_getOffset(v))
+}
+
+func ivar_getTypeEncoding(v cIvar) string {
+	return C.GoString(C.ivar_getTypeEncoding(v))
+}
+
+func object_getClass(obj cID) cClass {
+	return C.object_getClass(obj)
+}
+
+func object_setClass(obj cID, c cClass) cClass {
+	return C.object_setClass(obj, c)
+}
+
+func object_getClassName(obj cID) string {
+	return C.GoString(C.object_getClassName(obj))
+}
+
+func object_getIndexedIvars(obj cID) unsafe.Pointer {
+	return C.object_getIndexedIvars(obj)
+}
+
+func class_createInstance(c cClass, extra uintptr) cID {
+	return C.class_createInstance(c, C.size_t(extra))
+}
+
+func object_copy(obj cID, extra uintptr) cID {
+	return C.object_copy(obj, C.size_t(extra))
+}
+
+func object_dispose(obj cID) {
+	C.object_dispose(obj)
+}
+
+func class_copyPropertyList(c cClass) []cProp {
+	var n C.uint
+	buf := C.class_copyPropertyList(c, &n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]cProp, n)
+	copy(out, (*[1 << 28]cProp)(unsafe.Pointer(buf))[:n:n])
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func class_getProperty(c cClass, name string) cProp {
+	cstr := C.CString(name)
+	defer freeString(cstr)
+	return C.class_getProperty(c, cstr)
+}
+
+func property_getName(p cProp) string {
+	return C.GoString(C.property_getName(p))
+}
+
+func property_getAttributes(p cProp) string {
+	return C.GoString(C.property_getAttributes(p))
+}
+
+func cBool(v bool) C.BOOL {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func objc_getProtocol(name string) *cProtocol {
+	cstr := C.CString(name)
+	defer freeString(cstr)
+	return C.objc_getProtocol(cstr)
+}
+
+func objc_copyProtocolList() []*cProtocol {
+	var n C.uint
+	buf := C.objc_copyProtocolList(&n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]*cProtocol, n)
+	copy(out, (*[1 << 28]*cProtocol)(unsafe.Pointer(buf))[:n:n])
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func class_copyProtocolList(c cClass) []*cProtocol {
+	var n C.uint
+	buf := C.class_copyProtocolList(c, &n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]*cProtocol, n)
+	copy(out, (*[1 << 28]*cProtocol)(unsafe.Pointer(buf))[:n:n])
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func class_conformsToProtocol(c cClass, p *cProtocol) bool {
+	return C.class_conformsToProtocol(c, p) != 0
+}
+
+func protocol_getName(p *cProtocol) string {
+	return C.GoString(C.protocol_getName(p))
+}
+
+func protocol_isEqual(p1, p2 *cProtocol) bool {
+	return C.protocol_isEqual(p1, p2) != 0
+}
+
+func protocol_conformsToProtocol(p1, p2 *cProtocol) bool {
+	return C.protocol_conformsToProtocol(p1, p2) != 0
+}
+
+func protocol_copyProtocolList(p *cProtocol) []*cProtocol {
+	var n C.uint
+	buf := C.protocol_copyProtocolList(p, &n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]*cProtocol, n)
+	copy(out, (*[1 << 28]*cProtocol)(unsafe.Pointer(buf))[:n:n])
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func protocol_copyMethodDescriptionList(p *cProtocol, required, instance bool) []MethodDescription {
+	var n C.uint
+	buf := C.protocol_copyMethodDescriptionList(p, cBool(required), cBool(instance), &n)
+	if buf == nil {
+		return nil
+	}
+	out := make([]MethodDescription, 0, n)
+	for _, d := range (*[1 << 28]C.struct_objc_method_description)(unsafe.Pointer(buf))[:n:n] {
+		var name string
+		if d.name != nil {
+			name = sel_getName(d.name)
+		}
+		out = append(out, MethodDescription{Name: name, Types: C.GoString(d.types)})
+	}
+	free(unsafe.Pointer(buf))
+	return out
+}
+
+func sel_isEqual(s1, s2 cSEL) bool {
+	return C.sel_isEqual(s1, s2) != 0
+}
+
+// sel_registerTypedName registers a selector with a given type encoding.
+// It returns false if the runtime does not support typed selectors.
+func sel_registerTypedName(name, types string) (cSEL, bool) {
+	if C.go_sel_registerTypedName == nil {
+		return nil, false
+	}
+	cname := C.CString(name)
+	defer freeString(cname)
+	ctypes := C.CString(types)
+	defer freeString(ctypes)
+	return C.go_sel_registerTypedName_call(cname, ctypes), true
+}
+
+// sel_getType returns the type encoding of a typed selector, or an empty string if it's untyped.
+func sel_getType(sel cSEL) string {
+	if C.go_sel_getType == nil {
+		return ""
+	}
+	return C.GoString(C.go_sel_getType_call(sel))
+}
+
+// msgLookup returns a function that should be called to send a message to the object.
+func msgLookup(obj cID, sel cSEL) unsafe.Pointer {
+	return unsafe.Pointer(C.objc_msg_lookup(obj, sel))
+}
+
+func objc_allocateClassPair(super cClass, name string) cClass {
+	cstr := C.CString(name)
+	defer freeString(cstr)
+	return C.objc_allocateClassPair(super, cstr, 0)
+}
+
+func objc_registerClassPair(c cClass) {
+	C.objc_registerClassPair(c)
+}
+
+func objc_disposeClassPair(c cClass) {
+	C.objc_disposeClassPair(c)
+}
+
+func class_addMethod(c cClass, sel cSEL, imp unsafe.Pointer, types string) bool {
+	ctypes := C.CString(types)
+	defer freeString(ctypes)
+	return C.class_addMethod(c, sel, C.IMP(imp), ctypes) != 0
+}
+
+func class_addIvar(c cClass, name string, size uintptr, align uint8, types string) bool {
+	cname := C.CString(name)
+	defer freeString(cname)
+	ctypes := C.CString(types)
+	defer freeString(ctypes)
+	return C.class_addIvar(c, cname, C.size_t(size), C.uint8_t(align), ctypes) != 0
+}
+
+func class_addProtocol(c cClass, p *cProtocol) bool {
+	return C.class_addProtocol(c, p) != 0
+}
+
+func class_getMethodImplementation(c cClass, sel cSEL) unsafe.Pointer {
+	return unsafe.Pointer(C.class_getMethodImplementation(c, sel))
+}
+
+func class_replaceMethod(c cClass, sel cSEL, imp unsafe.Pointer, types string) unsafe.Pointer {
+	ctypes := C.CString(types)
+	defer freeString(ctypes)
+	return unsafe.Pointer(C.class_replaceMethod(c, sel, C.IMP(imp), ctypes))
+}
+
+func method_setImplementation(m cMethod, imp unsafe.Pointer) unsafe.Pointer {
+	return unsafe.Pointer(C.method_setImplementation(m, C.IMP(imp)))
+}
+
+func method_exchangeImplementations(m1, m2 cMethod) {
+	C.method_exchangeImplementations(m1, m2)
+}
+
+func objc_setAssociatedObject(obj cID, key unsafe.Pointer, val cID, policy uintptr) bool {
+	return C.go_objc_setAssociatedObject_call(obj, key, val, C.uintptr_t(policy)) != 0
+}
+
+func objc_getAssociatedObject(obj cID, key unsafe.Pointer) cID {
+	return C.go_objc_getAssociatedObject_call(obj, key)
+}
+
+func objc_removeAssociatedObjects(obj cID) {
+	C.go_objc_removeAssociatedObjects_call(obj)
+}
+
+func objc_autoreleasePoolPush() unsafe.Pointer {
+	return C.go_objc_autoreleasePoolPush_call()
+}
+
+func objc_autoreleasePoolPop(pool unsafe.Pointer) {
+	C.go_objc_autoreleasePoolPop_call(pool)
+}
+
+// objc_retain retains the object. It returns false if the runtime doesn't provide this function.
+func objc_retain(obj cID) bool {
+	return C.go_objc_retain_call(obj) != 0
+}
+
+// objc_release releases the object. It returns false if the runtime doesn't provide this function.
+func objc_release(obj cID) bool {
+	return C.go_objc_release_call(obj) != 0
+}
+
+// objc_autorelease autoreleases the object. It returns false if the runtime doesn't provide this function.
+func objc_autorelease(obj cID) bool {
+	return C.go_objc_autorelease_call(obj) != 0
+}
+
+// _Block_copy copies a block. It returns nil if the runtime doesn't support blocks.
+func _Block_copy(b unsafe.Pointer) unsafe.Pointer {
+	return C.go_Block_copy_call(b)
+}
+
+func _Block_release(b unsafe.Pointer) {
+	C.go_Block_release_call(b)
+}
+
+// blockStackClass returns the class of blocks allocated on the stack.
+// It returns nil if the runtime doesn't support blocks.
+func blockStackClass() unsafe.Pointer {
+	return C.go_block_stack_class()
+}
+
+// objc_initWeak initializes a weak location. It returns false if the runtime doesn't support weak references.
+// Other weak functions must only be called if this function succeeds.
+func objc_initWeak(loc *cID, obj cID) bool {
+	return C.go_objc_initWeak_call(loc, obj) != 0
+}
+
+func objc_storeWeak(loc *cID, obj cID) {
+	C.go_objc_storeWeak_call(loc, obj)
+}
+
+func objc_loadWeakRetained(loc *cID) cID {
+	return C.go_objc_loadWeakRetained_call(loc)
+}
+
+func objc_destroyWeak(loc *cID) {
+	C.go_objc_destroyWeak_call(loc)
+}
+
+func class_getImageName(c cClass) string {
+	return C.GoString(C.go_class_getImageName_call(c))
+}
+
+func objc_copyImageNames() []string {
+	var n C.uint
+	buf := C.go_objc_copyImageNames_call(&n)
+	return goStrings(buf, n)
+}
+
+func objc_copyClassNamesForImage(image string) []string {
+	cstr := C.CString(image)
+	defer freeString(cstr)
+	var n C.uint
+	buf := C.go_objc_copyClassNamesForImage_call(cstr, &n)
+	return goStrings(buf, n)
+}
+
+// imageGeneration returns a counter that is incremented each time a new image is loaded.
+// GNU runtimes do not report loaded images, thus the counter is not available.
+func imageGeneration() (uint64, bool) {
+	return 0, false
+}
+
+// runtimeInfo detects the runtime flavour and its capabilities.
+func runtimeInfo() RuntimeInfo {
+	f := C.go_objc_features()
+	info := RuntimeInfo{Flavor: FlavorUnknown, ABI: 1}
+	if f.gnustep != 0 {
+		info.Flavor = FlavorGNUstep
+	} else if f.gcc != 0 {
+		info.Flavor = FlavorGCC
+	}
+	if f.abi2 != 0 {
+		info.ABI = 2
+	}
+	for _, c := range []struct {
+		ok  C.int
+		cap Capability
+	}{
+		{f.nonfragile, CapNonFragileIvars},
+		{f.blocks, CapBlocks},
+		{f.arc, CapARC},
+		{f.weak, CapWeak},
+		{f.typed, CapTypedSelectors},
+		{f.assoc, CapAssociatedObjects},
+		{f.pools, CapAutoreleasePools},
+		{f.images, CapImages},
+	} {
+		if c.ok != 0 {
+			info.Capabilities |= c.cap
+		}
+	}
+	// GCC runtime only calls the legacy forward:: method, while GNUstep forwards
+	// messages with NSInvocation if Foundation is linked
+	if info.Flavor == FlavorGNUstep && objc_getClass("NSInvocation") != nil {
+		info.Capabilities |= CapForwarding
+	}
+	if catchesExceptions() {
+		info.Capabilities |= CapExceptions
+	}
+	return info
+}
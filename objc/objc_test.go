@@ -1,6 +1,20 @@
 package objc
 
-import "testing"
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/dennwc/go-apple/objc/encoding"
+)
 
 func TestGetClass(t *testing.T) {
 	c := GetClass("nonExistent")
@@ -34,3 +48,1734 @@ func TestListClasses(t *testing.T) {
 		t.Errorf("failed to find classes: %v", left)
 	}
 }
+
+func TestClassMethods(t *testing.T) {
+	c := GetClass("Object")
+	if c == nil {
+		t.Fatal("failed to get Object class")
+	}
+	list := c.Methods()
+	if len(list) == 0 {
+		t.Fatal("no methods")
+	}
+	t.Logf("methods: %v", list)
+	for _, m := range list {
+		name := m.Name()
+		if name == "" {
+			t.Errorf("empty method name: %v", m)
+			continue
+		}
+		if n := m.NumArguments(); n < 2 {
+			t.Errorf("%q: expected at least 2 arguments, got %d", name, n)
+		} else if sig, err := m.Signature(); err != nil {
+			t.Errorf("%q: %v", name, err)
+		} else if len(sig.Args) != n {
+			t.Errorf("%q: expected %d arguments in signature, got %d", name, n, len(sig.Args))
+		}
+		m2 := c.InstanceMethod(name)
+		if m2 == nil {
+			t.Errorf("%q: method not found", name)
+		} else if m.Implementation() != m2.Implementation() {
+			t.Errorf("%q: implementation mismatch", name)
+		}
+	}
+	if m := c.InstanceMethod("nonExistentMethod:"); m != nil {
+		t.Errorf("expected nil method: %v", m)
+	}
+}
+
+func TestClassIvars(t *testing.T) {
+	c := GetClass("Object")
+	if c == nil {
+		t.Fatal("failed to get Object class")
+	}
+	list := c.Ivars()
+	t.Logf("ivars: %v", list)
+	for _, v := range list {
+		name := v.Name()
+		if name == "" {
+			t.Errorf("empty ivar name: %v", v)
+			continue
+		}
+		v2 := c.Ivar(name)
+		if v2 == nil {
+			t.Errorf("%q: ivar not found", name)
+		} else if v.Offset() != v2.Offset() {
+			t.Errorf("%q: offset mismatch: %d vs %d", name, v.Offset(), v2.Offset())
+		}
+	}
+	if v := c.Ivar("nonExistentIvar"); v != nil {
+		t.Errorf("expected nil ivar: %v", v)
+	}
+}
+
+func TestIvarValues(t *testing.T) {
+	var buf [8]uint64
+	p := unsafe.Pointer(&buf[0])
+	for _, c := range []struct {
+		enc string
+		in  interface{}
+		out interface{}
+	}{
+		{enc: "c", in: 5, out: int8(5)},
+		{enc: "s", in: int16(-3), out: int16(-3)},
+		{enc: "i", in: uint8(7), out: int32(7)},
+		{enc: "q", in: int64(-1 << 40), out: int64(-1 << 40)},
+		{enc: "C", in: 200, out: uint8(200)},
+		{enc: "I", in: uint32(1 << 31), out: uint32(1 << 31)},
+		{enc: "Q", in: uint64(1 << 63), out: uint64(1 << 63)},
+		{enc: "f", in: 1.5, out: float32(1.5)},
+		{enc: "d", in: float32(2.5), out: float64(2.5)},
+		{enc: "B", in: true, out: true},
+		{enc: "^v", in: p, out: p},
+		{enc: "r^v", in: p, out: p},
+		{enc: "@", in: Object{}, out: Object{}},
+		{enc: `@"NSString"`, in: Object{}, out: Object{}},
+		{enc: ":", in: Selector{}, out: Selector{}},
+	} {
+		if err := writeValue(p, c.enc, c.in); err != nil {
+			t.Errorf("%q: %v", c.enc, err)
+			continue
+		}
+		got, err := readValue(p, c.enc)
+		if err != nil {
+			t.Errorf("%q: %v", c.enc, err)
+		} else if got != c.out {
+			t.Errorf("%q: expected %#v, got %#v", c.enc, c.out, got)
+		}
+	}
+	for _, c := range []struct {
+		enc string
+		in  interface{}
+	}{
+		{enc: "c", in: 128},
+		{enc: "C", in: -1},
+		{enc: "i", in: 1.0},
+		{enc: "B", in: 1},
+		{enc: "D", in: 1.0},
+		{enc: "{CGPoint=dd}", in: 0},
+	} {
+		if err := writeValue(p, c.enc, c.in); err == nil {
+			t.Errorf("%q: expected error for %#v", c.enc, c.in)
+		}
+	}
+	// long is sized according to the encoding package
+	exp := interface{}(int64(-5))
+	if encoding.Long.Size() == 4 {
+		exp = int32(-5)
+	}
+	if err := writeValue(p, "l", -5); err != nil {
+		t.Error(err)
+	} else if got, err := readValue(p, "l"); err != nil || got != exp {
+		t.Errorf("unexpected long: %#v, %v", got, err)
+	}
+}
+
+func TestParsePropertyAttributes(t *testing.T) {
+	for _, c := range []struct {
+		attrs string
+		exp   PropertyAttributes
+	}{
+		{
+			attrs: `Tc,VcharDefault`,
+			exp:   PropertyAttributes{Type: "c", Ivar: "charDefault"},
+		},
+		{
+			attrs: `Ti,R,VintReadonly`,
+			exp:   PropertyAttributes{Type: "i", ReadOnly: true, Ivar: "intReadonly"},
+		},
+		{
+			attrs: `Ti,GintGetFoo,SintSetFoo:,VintSetterGetter`,
+			exp:   PropertyAttributes{Type: "i", Getter: "intGetFoo", Setter: "intSetFoo:", Ivar: "intSetterGetter"},
+		},
+		{
+			attrs: `T@"NSString",C,N,V_name`,
+			exp:   PropertyAttributes{Type: `@"NSString"`, Copy: true, NonAtomic: true, Ivar: "_name"},
+		},
+		{
+			attrs: `T@,&,VidRetain`,
+			exp:   PropertyAttributes{Type: "@", Retain: true, Ivar: "idRetain"},
+		},
+		{
+			attrs: `T@,W,N`,
+			exp:   PropertyAttributes{Type: "@", Weak: true, NonAtomic: true},
+		},
+		{
+			attrs: `T{YorkshireTeaStruct=ic},D,N`,
+			exp:   PropertyAttributes{Type: "{YorkshireTeaStruct=ic}", Dynamic: true, NonAtomic: true},
+		},
+	} {
+		a, err := ParsePropertyAttributes(c.attrs)
+		if err != nil {
+			t.Errorf("%q: %v", c.attrs, err)
+		} else if *a != c.exp {
+			t.Errorf("%q: expected %+v, got %+v", c.attrs, c.exp, *a)
+		}
+	}
+	for _, s := range []string{"", "R,VintReadonly", "T", "Ti,,R"} {
+		if _, err := ParsePropertyAttributes(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestProtocols(t *testing.T) {
+	if p := GetProtocol("nonExistent"); p != nil {
+		t.Errorf("expected nil protocol: %v", p)
+	}
+	list := ListProtocols()
+	t.Logf("protocols: %v", list)
+	for _, p := range list {
+		name := p.Name()
+		p2 := GetProtocol(name)
+		if p2 == nil {
+			t.Errorf("%q: protocol not found", name)
+			continue
+		} else if !p2.Equal(&p) {
+			t.Errorf("%q: protocols are not equal", name)
+		}
+		if !p.ConformsTo(p2) {
+			t.Errorf("%q: protocol doesn't conform to itself", name)
+		}
+		for _, sub := range p.Protocols() {
+			if !p.ConformsTo(&sub) {
+				t.Errorf("%q: doesn't conform to adopted protocol %q", name, sub.Name())
+			}
+		}
+		for _, d := range p.MethodDescriptions(true, true) {
+			if d.Name == "" {
+				t.Errorf("%q: empty method name", name)
+			}
+		}
+	}
+	for _, c := range ListClasses() {
+		for _, p := range c.Protocols() {
+			if !c.ConformsTo(&p) {
+				t.Errorf("%q: doesn't conform to adopted protocol %q", c.Name(), p.Name())
+			}
+		}
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	const name = "performSelector:withObject:"
+	s1 := RegisterSelector(name)
+	if s1.IsNil() {
+		t.Fatal("nil selector")
+	} else if got := s1.Name(); got != name {
+		t.Errorf("invalid name: %q", got)
+	}
+	s2 := RegisterSelector(name)
+	if !s1.Equal(s2) {
+		t.Errorf("selectors are not equal: %v vs %v", s1, s2)
+	}
+	if s3 := RegisterSelector("init"); s1.Equal(s3) {
+		t.Errorf("selectors should not be equal: %v vs %v", s1, s3)
+	}
+	if s1.Equal(Selector{}) {
+		t.Error("selector should not be equal to nil")
+	}
+
+	const types = "@32@0:8:16@24"
+	ts := RegisterTypedSelector(name, types)
+	if ts.IsNil() {
+		t.Fatal("nil typed selector")
+	} else if got := ts.Name(); got != name {
+		t.Errorf("invalid name: %q", got)
+	} else if !ts.Equal(s1) {
+		t.Errorf("typed selector is not equal to untyped: %v vs %v", ts, s1)
+	}
+	if enc := ts.TypeEncoding(); enc != "" && enc != types {
+		t.Errorf("invalid type encoding: %q", enc)
+	}
+}
+
+func TestSend(t *testing.T) {
+	c := GetClass("Object")
+	if c == nil {
+		t.Fatal("failed to get Object class")
+	}
+	res, err := c.Send(RegisterSelector("class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cl, ok := res.(*Class); !ok || !cl.Valid() {
+		t.Errorf("unexpected result: %#v", res)
+	}
+	if _, err = c.Send(RegisterSelector("class"), 1); err == nil {
+		t.Error("expected an error for invalid number of arguments")
+	}
+	if _, err = c.Send(RegisterSelector("nonExistentMethod")); err == nil {
+		t.Error("expected an error for unknown method")
+	}
+	res, err = Object{}.Send(RegisterSelector("class"))
+	if err != nil || res != nil {
+		t.Errorf("unexpected result for nil object: %v, %v", res, err)
+	}
+}
+
+func TestGoMethod(t *testing.T) {
+	m, err := newGoMethod(func(self Object, cmd Selector, a int32, b float64, c bool, d float32) float64 {
+		if !self.IsNil() || !cmd.IsNil() {
+			t.Errorf("unexpected receiver: %v %v", self, cmd)
+		}
+		if !c {
+			return 0
+		}
+		return float64(a) * b * float64(d)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types := m.types(); types != "d@:idBf" {
+		t.Errorf("unexpected types: %q", types)
+	}
+	var a callArgs
+	for i, v := range []interface{}{3, 1.5, true, float32(2)} {
+		if err := a.add(m.args[i], v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := m.call(Object{}, Selector{}, &a.ints, &a.floats)
+	if got := math.Float64frombits(w); got != 9 {
+		t.Errorf("unexpected result: %v", got)
+	}
+
+	for _, fnc := range []interface{}{
+		nil,
+		func() {},
+		func(self Object) {},
+		func(self Object, cmd Selector, s string) {},
+		func(self Object, cmd Selector) (int, error) { return 0, nil },
+		func(self Object, cmd Selector, a, b, c, d, e int) {},
+	} {
+		if _, err := newGoMethod(fnc); err == nil {
+			t.Errorf("expected error for %T", fnc)
+		}
+	}
+}
+
+func TestGoMethodPanic(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	m, err := newGoMethod(func(self Object, cmd Selector, v int32) int32 {
+		panic("method failed")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := uint64(1)
+	func() {
+		// same as the callback of blocks, which doesn't have a selector
+		defer recoverCallback(nil, &out)
+		var ints [maxIntArgs]uint64
+		var floats [maxFloatArgs]float64
+		out = m.call(Object{}, Selector{}, &ints, &floats)
+	}()
+	if out != 0 {
+		t.Errorf("expected zero result, got %d", out)
+	}
+	if s := buf.String(); !strings.Contains(s, "block") || !strings.Contains(s, "method failed") {
+		t.Errorf("unexpected log: %q", s)
+	}
+}
+
+func TestAllocateClassPair(t *testing.T) {
+	super := GetClass("Object")
+	if super == nil {
+		t.Fatal("failed to get Object class")
+	}
+	b, err := AllocateClassPair(super, "GoTestAllocateClassPair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = b.AddIvar("value", "i"); err != nil {
+		t.Fatal(err)
+	}
+	// alignment of long double differs from its size on some platforms
+	if err = b.AddIvar("ld", "D"); err != nil {
+		t.Fatal(err)
+	}
+	sel := RegisterSelector("add:to:")
+	err = b.AddClassMethod(sel, func(self Object, cmd Selector, a, b int32) int32 {
+		return a + b
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.AddClassMethod(RegisterSelector("half:"), func(self Object, cmd Selector, v float32) float32 {
+		return v / 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = b.AddClassMethod(sel, func(self Object, cmd Selector) {}); err == nil {
+		t.Error("expected an error for duplicate method")
+	}
+	c := b.Register()
+	defer DisposeClassPair(c)
+	if err = b.AddIvar("late", "i"); err == nil {
+		t.Error("expected an error for adding ivar after registration")
+	}
+
+	if c2 := GetClass("GoTestAllocateClassPair"); c2 == nil {
+		t.Fatal("class is not registered")
+	} else if s := c2.GetSuperclass(); s == nil || s.Name() != "Object" {
+		t.Errorf("unexpected superclass: %v", s)
+	}
+	if v := c.Ivar("value"); v == nil {
+		t.Error("ivar was not added")
+	}
+	res, err := c.Send(sel, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	} else if res != int32(5) {
+		t.Errorf("unexpected result: %#v", res)
+	}
+	res, err = c.Send(RegisterSelector("half:"), float32(3))
+	if err != nil {
+		t.Fatal(err)
+	} else if res != float32(1.5) {
+		t.Errorf("unexpected result: %#v", res)
+	}
+}
+
+func TestGoSubclassMethod(t *testing.T) {
+	b, err := AllocateClassPair(GetClass("Object"), "GoTestSuperMethodBase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := RegisterSelector("next:")
+	err = b.AddMethod(sel, func(self Object, cmd Selector, v int32) int32 {
+		return v + 1
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := b.Register()
+	defer DisposeClassPair(base)
+
+	b, err = AllocateClassPair(base, "GoTestSuperMethodSub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	super := base.InstanceMethod(sel.Name()).Implementation()
+	err = b.AddMethod(sel, func(self Object, cmd Selector, v int32) int32 {
+		res, err := self.SendIMP(super, cmd, v)
+		if err != nil {
+			t.Error(err)
+			return -1
+		}
+		return res.(int32) * 10
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := b.Register()
+	defer DisposeClassPair(sub)
+
+	for _, c := range []struct {
+		class *Class
+		exp   int32
+	}{
+		{class: base, exp: 3},
+		{class: sub, exp: 30},
+	} {
+		obj, err := c.class.CreateInstance(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, err := obj.Send(sel, 2); err != nil {
+			t.Error(err)
+		} else if res != c.exp {
+			t.Errorf("%s: unexpected result: %#v", c.class.Name(), res)
+		}
+		obj.Dispose()
+	}
+}
+
+func TestParseClassTag(t *testing.T) {
+	for _, c := range []struct {
+		tag    string
+		name   string
+		super  string
+		protos []string
+	}{
+		{tag: "Foo : Object", name: "Foo", super: "Object"},
+		{tag: "Foo:Object<A>", name: "Foo", super: "Object", protos: []string{"A"}},
+		{tag: " Foo : Object <A, B> ", name: "Foo", super: "Object", protos: []string{"A", "B"}},
+		{tag: ""},
+		{tag: "Foo"},
+		{tag: "Foo :"},
+		{tag: "Foo : Object <A"},
+		{tag: "Foo : Object <A> B"},
+		{tag: "Foo : Bar : Object"},
+	} {
+		name, super, protos, err := parseClassTag(c.tag)
+		if c.name == "" {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.tag)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%q: %v", c.tag, err)
+			continue
+		}
+		if name != c.name || super != c.super || fmt.Sprint(protos) != fmt.Sprint(c.protos) {
+			t.Errorf("%q: unexpected result: %q %q %q", c.tag, name, super, protos)
+		}
+	}
+	for _, c := range []struct {
+		name  string
+		nargs int
+		sel   string
+	}{
+		{name: "Description", nargs: 0, sel: "description"},
+		{name: "SetValue", nargs: 1, sel: "setValue:"},
+		{name: "SetValue_", nargs: 1, sel: "setValue:"},
+		{name: "Add_to_", nargs: 2, sel: "add:to:"},
+		{name: "Add_to", nargs: 2, sel: "add:to:"},
+		{name: "Add_to", nargs: 3},
+		{name: "Value", nargs: 2},
+	} {
+		sel, err := methodSelector(c.name, c.nargs)
+		if c.sel == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error", c.name)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if sel != c.sel {
+			t.Errorf("%s: expected %q, got %q", c.name, c.sel, sel)
+		}
+	}
+}
+
+type goTestRegisterClass struct {
+	Object `objc:"GoTestRegisterClass : Object"`
+	value  int32
+}
+
+func (v *goTestRegisterClass) Value() int32 {
+	return v.value
+}
+
+func (v *goTestRegisterClass) SetValue(val int32) {
+	v.value = val
+}
+
+func (v *goTestRegisterClass) Add_to_(a, b float64) float64 {
+	return a + b
+}
+
+func TestRegisterClass(t *testing.T) {
+	c, err := RegisterClass((*goTestRegisterClass)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DisposeClassPair(c)
+	if c.Name() != "GoTestRegisterClass" {
+		t.Errorf("unexpected name: %q", c.Name())
+	}
+	for _, m := range []struct {
+		sel   string
+		types string
+	}{
+		{"value", "i@:"},
+		{"setValue:", "v@:i"},
+		{"add:to:", "d@:dd"},
+	} {
+		if im := c.InstanceMethod(m.sel); im == nil {
+			t.Errorf("method %q was not added", m.sel)
+		} else if types := im.TypeEncoding(); types != m.types {
+			t.Errorf("%q: unexpected types: %q", m.sel, types)
+		}
+	}
+	if c.Ivar(handleIvar) == nil {
+		t.Error("handle ivar was not added")
+	}
+	if _, err = RegisterClass(goTestRegisterClass{}); err == nil {
+		t.Error("expected an error for duplicate class")
+	}
+	if _, err = RegisterClass(struct{ value int }{}); err == nil {
+		t.Error("expected an error for struct without Object")
+	}
+}
+
+type goTestSelectors struct {
+	Object `objc:"GoTestSelectors : Object"`
+}
+
+func (v *goTestSelectors) Selectors() map[string]string {
+	return map[string]string{
+		"URLForKey": "URLForKey:",
+		"Copy":      "copy",
+	}
+}
+
+func (v *goTestSelectors) URLForKey(key Object) Object {
+	return key
+}
+
+// Copy shadows Object.Copy, thus it's only registered with an explicit selector.
+func (v *goTestSelectors) Copy() Object {
+	return v.Object
+}
+
+type goTestShadow struct {
+	Object `objc:"GoTestShadow : Object"`
+}
+
+func (v goTestShadow) String() string {
+	return "shadow"
+}
+
+type goTestBadSelectors struct {
+	Object `objc:"GoTestBadSelectors : Object"`
+}
+
+func (v goTestBadSelectors) Selectors() map[string]string {
+	return map[string]string{"Missing": "missing"}
+}
+
+func TestRegisterSelectors(t *testing.T) {
+	c, err := RegisterClass((*goTestSelectors)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DisposeClassPair(c)
+	for _, sel := range []string{"URLForKey:", "copy"} {
+		if c.InstanceMethod(sel) == nil {
+			t.Errorf("method %q was not added", sel)
+		}
+	}
+	for _, sel := range []string{"uRLForKey:", "selectors"} {
+		if c.InstanceMethod(sel) != nil {
+			t.Errorf("unexpected method %q", sel)
+		}
+	}
+	if _, err = RegisterClass((*goTestShadow)(nil)); err == nil || !strings.Contains(err.Error(), "shadows") {
+		t.Errorf("expected an error for a method that shadows Object: %v", err)
+	}
+	if _, err = RegisterClass((*goTestBadSelectors)(nil)); err == nil || !strings.Contains(err.Error(), "unknown method") {
+		t.Errorf("expected an error for a selector of unknown method: %v", err)
+	}
+}
+
+func TestRegisterGeneratedClass(t *testing.T) {
+	p := GetProtocol("NSCopying")
+	if p == nil {
+		t.Skip("NSCopying protocol is not available")
+	}
+	// the tag is emitted by the generator for protocol wrappers, see generator/protocol_test.go
+	tag, err := ioutil.ReadFile("testdata/protocol_tag.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ := reflect.StructOf([]reflect.StructField{{
+		Name: "Object", Type: objectType, Anonymous: true,
+		Tag: reflect.StructTag(strings.TrimSpace(string(tag))),
+	}})
+	c, err := RegisterClass(reflect.New(typ).Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DisposeClassPair(c)
+	if s := c.GetSuperclass(); s == nil || s.Name() != "NSObject" {
+		t.Errorf("unexpected superclass: %v", s)
+	}
+	if !c.ConformsTo(p) {
+		t.Error("class doesn't conform to the protocol")
+	}
+}
+
+type goTestDealloc struct {
+	Object `objc:"GoTestDealloc : NSObject"`
+	freed  *bool
+}
+
+func (v *goTestDealloc) Dealloc() {
+	*v.freed = true
+}
+
+func TestDealloc(t *testing.T) {
+	if GetClass("NSObject") == nil {
+		t.Skip("NSObject is not available")
+	}
+	c, err := RegisterClass((*goTestDealloc)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DisposeClassPair(c)
+	res, err := c.Send(RegisterSelector("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := res.(Object)
+	freed := false
+	obj.GoValue().(*goTestDealloc).freed = &freed
+	w, err := NewWeak(obj)
+	if err == nil {
+		defer w.Close()
+	}
+	obj.Retain()
+	obj.Release()
+	if freed {
+		t.Fatal("object was deallocated while retained")
+	}
+	obj.Release()
+	if !freed {
+		t.Error("object was not deallocated")
+	}
+	if w != nil {
+		if got := w.Load().Object(); !got.IsNil() {
+			t.Errorf("expected nil object: %v", got)
+		}
+	}
+}
+
+type goTestDeallocSub struct {
+	Object `objc:"GoTestDeallocSub : GoTestDealloc"`
+	freed  *bool
+}
+
+func (v *goTestDeallocSub) Dealloc() {
+	*v.freed = true
+}
+
+func (v *goTestDeallocSub) Hash() uintptr {
+	return 42
+}
+
+func TestDeallocSubclass(t *testing.T) {
+	if GetClass("NSObject") == nil {
+		t.Skip("NSObject is not available")
+	}
+	base, err := RegisterClass((*goTestDealloc)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DisposeClassPair(base)
+	c, err := RegisterClass((*goTestDeallocSub)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DisposeClassPair(c)
+	res, err := c.Send(RegisterSelector("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := res.(Object)
+	var freed, baseFreed bool
+	obj.GoValue().(*goTestDeallocSub).freed = &freed
+	// methods of the superclass receive their own Go value
+	if v, ok := findGoClass(base.class).value(obj).Interface().(*goTestDealloc); !ok {
+		t.Fatalf("unexpected superclass value: %T", v)
+	} else {
+		v.freed = &baseFreed
+	}
+	if res, err := obj.Send(RegisterSelector("hash")); err != nil {
+		t.Error(err)
+	} else if v, err := uintValue(res, encoding.UnsignedLongLong); err != nil || v != 42 {
+		t.Errorf("unexpected hash: %#v", res)
+	}
+	obj.Release()
+	if !freed || !baseFreed {
+		t.Errorf("object was not deallocated: %v, %v", freed, baseFreed)
+	}
+}
+
+func TestCompatibleTypes(t *testing.T) {
+	m, err := newGoMethod(func(self Object, cmd Selector, a int32, b Object, c float64) bool { return false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		types string
+		ok    bool
+	}{
+		{"B@:i@d", true},
+		{"c28@0:8ri16@\"NSString\"20d24", true},
+		{"C@:I^vd", true},
+		{"c@:i#d", true},
+		{"B@:i@", false},
+		{"B@:i@f", false},
+		{"B@:q@d", false},
+		{"v@:i@d", false},
+		{"B@:i{S=ii}d", false},
+	} {
+		if err := m.checkTypes(c.types); c.ok && err != nil {
+			t.Errorf("%q: %v", c.types, err)
+		} else if !c.ok && err == nil {
+			t.Errorf("%q: expected an error", c.types)
+		}
+	}
+}
+
+func TestReplaceMethod(t *testing.T) {
+	b, err := AllocateClassPair(GetClass("Object"), "GoTestReplaceMethod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.Register()
+	defer DisposeClassPair(c)
+
+	sel := RegisterSelector("isEqual:")
+	var orig IMP
+	calls := 0
+	orig, err = c.ReplaceClassMethod(sel, func(self Object, cmd Selector, o Object) bool {
+		calls++
+		res, err := self.SendIMP(orig, cmd, o)
+		if err != nil {
+			t.Error(err)
+			return false
+		}
+		return res.(bool)
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if orig == nil {
+		t.Fatal("nil original implementation")
+	}
+	if res, err := c.Send(sel, c); err != nil {
+		t.Fatal(err)
+	} else if res != true || calls != 1 {
+		t.Errorf("unexpected result: %v, calls: %d", res, calls)
+	}
+	if _, err = c.ReplaceClassMethod(sel, func(self Object, cmd Selector, o Object) bool { return false }); err == nil {
+		t.Error("expected an error for replacing a Go method")
+	}
+	if _, err = c.ReplaceMethod(RegisterSelector("nonExistentMethod:"), func(self Object, cmd Selector, o Object) {}); err == nil {
+		t.Error("expected an error for a missing method")
+	}
+
+	m := c.Object().Class().InstanceMethod(sel.Name())
+	if m == nil {
+		t.Fatal("method not found")
+	} else if prev := m.SetImplementation(orig); prev == nil || prev == orig {
+		t.Errorf("unexpected previous implementation: %v", prev)
+	}
+}
+
+func TestAssociatedObjects(t *testing.T) {
+	c := GetClass("Object")
+	if c == nil {
+		t.Fatal("failed to get Object class")
+	}
+	b, err := AllocateClassPair(c, "GoTestAssociatedObjects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2 := b.Register()
+	defer DisposeClassPair(c2)
+
+	obj, val := c2.Object(), c.Object()
+	key := NewAssociationKey()
+	if err = obj.SetAssociatedObject(key, val, AssociationAssign); err != nil {
+		t.Skip(err)
+	}
+	if got := obj.AssociatedObject(key); got != val {
+		t.Errorf("unexpected associated object: %v", got)
+	}
+	if got := obj.AssociatedObject(NewAssociationKey()); !got.IsNil() {
+		t.Errorf("expected nil object: %v", got)
+	}
+	obj.RemoveAssociatedObjects()
+	if got := obj.AssociatedObject(key); !got.IsNil() {
+		t.Errorf("expected nil object: %v", got)
+	}
+
+	if GetClass("NSObject") == nil {
+		t.Skip("NSObject is not available")
+	}
+	type state struct{ n int }
+	if err = obj.SetAssociatedValue(key, &state{n: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := obj.AssociatedValue(key).(*state); !ok || s.n != 1 {
+		t.Errorf("unexpected associated value: %v", obj.AssociatedValue(key))
+	}
+	if err = obj.SetAssociatedValue(key, nil); err != nil {
+		t.Fatal(err)
+	} else if v := obj.AssociatedValue(key); v != nil {
+		t.Errorf("expected nil value: %v", v)
+	}
+}
+
+func TestBox(t *testing.T) {
+	if GetClass("NSObject") == nil {
+		t.Skip("NSObject is not available")
+	}
+	type state struct{ n int }
+	v := &state{n: 1}
+	b, err := Box(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := b.Class(); c == nil || c.Name() != "GoBox" || !c.IsSubclassOf(GetClass("NSObject")) {
+		t.Errorf("unexpected class: %v", c)
+	}
+	if got, ok := Unbox(b); !ok || got != v {
+		t.Errorf("unexpected value: %v, %v", got, ok)
+	}
+	if got, ok := Unbox(GetClass("NSObject").Object()); ok {
+		t.Errorf("unexpected value for non-box object: %v", got)
+	}
+	if got, ok := Unbox(Object{}); ok {
+		t.Errorf("unexpected value for nil object: %v", got)
+	}
+	nb, err := Box(nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if got, ok := Unbox(nb); !ok || got != nil {
+		t.Errorf("unexpected value: %v, %v", got, ok)
+	}
+	nb.Release()
+
+	w, err := NewWeak(b)
+	b.Release()
+	if err == nil {
+		if got := w.Load().Object(); !got.IsNil() {
+			t.Errorf("box was not deallocated: %v", got)
+		}
+		w.Close()
+	}
+}
+
+func TestAutoreleasePool(t *testing.T) {
+	if !Runtime().Has(CapAutoreleasePools) {
+		if p, err := PushPool(); err == nil || p != nil {
+			t.Fatal("expected an error for unsupported autorelease pools")
+		}
+		pools.Lock()
+		n := len(pools.byThread)
+		pools.Unlock()
+		if n != 0 {
+			t.Errorf("unexpected active pools: %d", n)
+		}
+		called := false
+		err := WithAutoreleasePool(func() {
+			called = true
+		})
+		if err == nil || called {
+			t.Errorf("function was called without a pool: %v", err)
+		}
+		t.Skip("autorelease pools are not supported")
+	}
+	called := false
+	err := WithAutoreleasePool(func() {
+		called = true
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if !called {
+		t.Error("function was not called")
+	}
+
+	p1, err := PushPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := PushPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := PopPool(p1); err == nil {
+		t.Error("expected an error for unbalanced pop")
+	}
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		errc <- PopPool(p2)
+	}()
+	if err := <-errc; err == nil {
+		t.Error("expected an error for pop on a wrong thread")
+	}
+	if err := PopPool(p2); err != nil {
+		t.Fatal(err)
+	}
+	if err := PopPool(p2); err == nil {
+		t.Error("expected an error for double pop")
+	}
+	if err := PopPool(p1); err != nil {
+		t.Fatal(err)
+	}
+
+	// inner pools left active are popped with the outer one
+	var (
+		inner *AutoreleasePool
+		ierr  error
+	)
+	err = WithAutoreleasePool(func() {
+		inner, ierr = PushPool()
+	})
+	if err == nil {
+		t.Error("expected an error for unbalanced inner pool")
+	}
+	if ierr != nil {
+		t.Fatal(ierr)
+	} else if err := PopPool(inner); err == nil {
+		t.Error("expected inner pool to be popped")
+	}
+	pools.Lock()
+	n := len(pools.byThread)
+	pools.Unlock()
+	if n != 0 {
+		t.Errorf("unexpected active pools: %d", n)
+	}
+}
+
+func TestMainThread(t *testing.T) {
+	if IsMainThread() {
+		t.Skip("test runs on the main thread")
+	}
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("expected a panic")
+			}
+		}()
+		MainThread(func() {})
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	started := make(chan uintptr, 1)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		started <- threadID()
+		serveMain(done)
+	}()
+	tid := <-started
+
+	var got uintptr
+	OnMainSync(func() {
+		got = threadID()
+	})
+	if got != tid {
+		t.Errorf("function was called on a wrong thread: %#x vs %#x", got, tid)
+	}
+	order := make(chan int, 2)
+	OnMain(func() { order <- 1 })
+	OnMain(func() { order <- 2 })
+	if a, b := <-order, <-order; a != 1 || b != 2 {
+		t.Errorf("unexpected order: %d, %d", a, b)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("unexpected panic: %v", r)
+			}
+		}()
+		OnMainSync(func() { panic("boom") })
+	}()
+}
+
+func TestRef(t *testing.T) {
+	c := GetClass("Object")
+	if c == nil {
+		t.Fatal("failed to get Object class")
+	}
+	obj := c.Object()
+	r := Retain(obj)
+	if got := r.Object(); got != obj {
+		t.Errorf("unexpected object: %v", got)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	} else if got := r.Object(); !got.IsNil() {
+		t.Errorf("expected nil object after close: %v", got)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r = Adopt(obj.Retain())
+	got := r.Detach()
+	if got != obj {
+		t.Errorf("unexpected object: %v", got)
+	}
+	got.Release()
+	if got := Adopt(Object{}).Object(); !got.IsNil() {
+		t.Errorf("expected nil object: %v", got)
+	}
+}
+
+func TestException(t *testing.T) {
+	var err error = &Exception{Name: "NSInvalidArgumentException", Reason: "bad argument"}
+	if s := err.Error(); s != "objc: exception: NSInvalidArgumentException: bad argument" {
+		t.Errorf("unexpected error: %q", s)
+	}
+	if e := err.(*Exception); !e.Object().IsNil() {
+		t.Errorf("expected nil object: %v", e.Object())
+	}
+	res, exc, err := Object{}.TrySend(Selector{})
+	if err == nil || exc != nil || res != nil {
+		t.Errorf("unexpected result: %v, %v, %v", res, exc, err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("expected a panic")
+			}
+		}()
+		Object{}.MustSend(Selector{})
+	}()
+}
+
+type goTestRaise struct {
+	Object `objc:"GoTestRaise : NSObject"`
+}
+
+func (v *goTestRaise) Raise(e Object) {
+	panic(newException(e))
+}
+
+func TestExceptionRaise(t *testing.T) {
+	if !Runtime().Has(CapExceptions) {
+		_, _, err := Object{}.TrySend(Selector{})
+		if err == nil {
+			t.Error("expected an error when exceptions are not caught")
+		}
+		t.Skip("exceptions are not caught")
+	} else if Runtime().Flavor != FlavorFake {
+		t.Skip("Go methods can only raise exceptions in the fake runtime")
+	}
+	c, err := RegisterClass((*goTestRaise)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DisposeClassPair(c)
+	res, err := c.Send(RegisterSelector("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := res.(Object)
+	defer obj.Release()
+	res, err = GetClass("NSObject").Send(RegisterSelector("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eobj := res.(Object)
+	defer eobj.Release()
+
+	raise := RegisterSelector("raise:")
+	res, exc, err := obj.TrySend(raise, eobj)
+	if err != nil {
+		t.Fatal(err)
+	} else if exc == nil {
+		t.Fatalf("expected an exception, got: %v", res)
+	}
+	if exc.Name != "NSObject" || exc.Reason != "" {
+		t.Errorf("unexpected exception: %v", exc)
+	}
+	if exc.Object() != eobj {
+		t.Errorf("unexpected exception object: %v", exc.Object())
+	}
+	func() {
+		defer func() {
+			if e, ok := recover().(*Exception); !ok || e.Object() != eobj {
+				t.Errorf("unexpected panic: %v", e)
+			}
+		}()
+		obj.MustSend(raise, eobj)
+	}()
+}
+
+func TestBlock(t *testing.T) {
+	if _, err := NewBlock(func(s string) {}); err == nil {
+		t.Error("expected an error for unsupported argument")
+	}
+	calls := 0
+	b, err := NewBlock(func(a int32, b float64, c Object) float64 {
+		calls++
+		if !c.IsNil() {
+			return 0
+		}
+		return float64(a) * b
+	})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer b.Release()
+	if sig := b.Signature(); sig != "d@?id@" {
+		t.Errorf("unexpected signature: %q", sig)
+	}
+	res, err := b.Call(int32(2), 1.5, Object{})
+	if err != nil {
+		t.Fatal(err)
+	} else if res != 3.0 || calls != 1 {
+		t.Errorf("unexpected result: %v, calls: %d", res, calls)
+	}
+	if _, err = b.Call(1); err == nil {
+		t.Error("expected an error for wrong number of arguments")
+	}
+	b2 := b.Copy()
+	res, err = b2.CallTypes("d@?id@", 3, 2.0, Object{})
+	b2.Release()
+	if err != nil {
+		t.Fatal(err)
+	} else if res != 6.0 || calls != 2 {
+		t.Errorf("unexpected result: %v, calls: %d", res, calls)
+	}
+}
+
+func TestWeak(t *testing.T) {
+	c := GetClass("Object")
+	if c == nil {
+		t.Fatal("failed to get Object class")
+	}
+	obj := c.Object()
+	w, err := NewWeak(obj)
+	if err != nil {
+		t.Skip(err)
+	}
+	r := w.Load()
+	if got := r.Object(); got != obj {
+		t.Errorf("unexpected object: %v", got)
+	}
+	r.Close()
+	w.Store(Object{})
+	if got := w.Load().Object(); !got.IsNil() {
+		t.Errorf("expected nil object: %v", got)
+	}
+	w.Store(obj)
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Load().Object(); !got.IsNil() {
+		t.Errorf("expected nil object after close: %v", got)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClassHierarchy(t *testing.T) {
+	root := GetClass("Object")
+	if root == nil {
+		t.Fatal("failed to get Object class")
+	}
+	b, err := AllocateClassPair(root, "GoTestClassHierarchy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.Register()
+	defer DisposeClassPair(c)
+
+	if m := c.Metaclass(); m == nil || !m.IsMetaClass() || m.Name() != c.Name() {
+		t.Errorf("unexpected metaclass: %v", m)
+	}
+	if !c.IsSubclassOf(root) || !c.IsSubclassOf(c) || root.IsSubclassOf(c) {
+		t.Error("unexpected subclass relation")
+	}
+	if h := c.Hierarchy(); len(h) != 2 || h[0] != *c || h[1] != *root {
+		t.Errorf("unexpected hierarchy: %v", h)
+	}
+	found := false
+	for _, s := range root.Subclasses() {
+		if s == *c {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("class is not listed as a subclass")
+	}
+
+	tree := NewClassTree()
+	if sub := tree.Subclasses(c); len(sub) != 0 {
+		t.Errorf("unexpected subclasses: %v", sub)
+	}
+	depth := -1
+	tree.Walk(func(cl Class, d int) bool {
+		if cl == *c {
+			depth = d
+		}
+		return true
+	})
+	if depth != 1 {
+		t.Errorf("unexpected depth: %d", depth)
+	}
+	n := 0
+	tree.WalkFrom(root, func(cl Class, d int) bool {
+		n++
+		return d == 0
+	})
+	if n != len(tree.Subclasses(root))+1 {
+		t.Errorf("unexpected number of visited classes: %d", n)
+	}
+}
+
+func TestImages(t *testing.T) {
+	c := GetClass("Object")
+	if c == nil {
+		t.Fatal("failed to get Object class")
+	}
+	image := c.ImageName()
+	if image == "" {
+		t.Skip("image names are not supported")
+	}
+	found := false
+	for _, name := range ImageNames() {
+		if name == image {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("image %q is not listed", image)
+	}
+	found = false
+	for _, cl := range ClassesForImage(image) {
+		if cl == *c {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("class is not listed for image %q", image)
+	}
+	if list := ClassNamesForImage("nonExistentImage"); len(list) != 0 {
+		t.Errorf("unexpected classes: %v", list)
+	}
+}
+
+func TestClasses(t *testing.T) {
+	n := 0
+	Classes(func(c Class) bool {
+		n++
+		return true
+	})
+	if exp := len(ListClasses()); n != exp {
+		t.Errorf("expected %d classes, got %d", exp, n)
+	}
+	n = 0
+	Classes(func(c Class) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("iteration was not stopped: %d", n)
+	}
+
+	before := len(CachedClasses())
+	b, err := AllocateClassPair(GetClass("Object"), "GoTestClasses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.Register()
+	defer DisposeClassPair(c)
+	if after := len(CachedClasses()); after != before+1 {
+		t.Errorf("cache was not invalidated: %d vs %d", after, before)
+	}
+
+	// classes replaced by other code don't change the number of classes
+	hasClass := func(name string) bool {
+		for _, c := range CachedClasses() {
+			if c.Name() == name {
+				return true
+			}
+		}
+		return false
+	}
+	raw := objc_allocateClassPair(GetClass("Object").class, "GoTestRawClass1")
+	objc_registerClassPair(raw)
+	if !hasClass("GoTestRawClass1") {
+		t.Error("new class is not listed")
+	}
+	objc_disposeClassPair(raw)
+	raw = objc_allocateClassPair(GetClass("Object").class, "GoTestRawClass2")
+	objc_registerClassPair(raw)
+	defer objc_disposeClassPair(raw)
+	InvalidateClassCache()
+	if !hasClass("GoTestRawClass2") || hasClass("GoTestRawClass1") {
+		t.Error("replaced class is not listed after invalidation")
+	}
+
+	var found []string
+	ClassesWithPrefix("GoTestCl", func(c Class) bool {
+		found = append(found, c.Name())
+		return true
+	})
+	if len(found) != 1 || found[0] != "GoTestClasses" {
+		t.Errorf("unexpected classes: %v", found)
+	}
+}
+
+func TestRuntime(t *testing.T) {
+	seen := make(map[string]bool)
+	for c, name := range capNames {
+		if c == 0 || c&(c-1) != 0 {
+			t.Errorf("%s: expected a single capability, got %#x", name, uint(c))
+		} else if seen[name] {
+			t.Errorf("duplicate capability name: %s", name)
+		}
+		seen[name] = true
+	}
+	if s := (CapExceptions | 1<<31).String(); s != "exceptions,0x80000000" {
+		t.Errorf("unexpected capabilities: %q", s)
+	}
+	if s := (CapBlocks | CapWeak).String(); s != "blocks,weak" {
+		t.Errorf("unexpected capabilities: %q", s)
+	}
+	if c := CapBlocks | CapWeak; !c.Has(CapWeak) || c.Has(CapWeak|CapARC) {
+		t.Error("unexpected capability check")
+	}
+	info := Runtime()
+	t.Logf("runtime: %v, ABI: %d, capabilities: %v", info.Flavor, info.ABI, info.Capabilities)
+	if info.ABI != 1 && info.ABI != 2 {
+		t.Errorf("unexpected ABI: %d", info.ABI)
+	}
+	_, err := NewBlock(func() {})
+	if info.Has(CapBlocks) != (err == nil) {
+		t.Errorf("unexpected blocks support: %v", err)
+	}
+	w, err := NewWeak(Object{})
+	if info.Has(CapWeak) != (err == nil) {
+		t.Errorf("unexpected weak references support: %v", err)
+	}
+	w.Close()
+}
+
+func TestInstance(t *testing.T) {
+	b, err := AllocateClassPair(GetClass("Object"), "GoTestInstance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = b.AddIvar("value", "i"); err != nil {
+		t.Fatal(err)
+	}
+	c := b.Register()
+	defer DisposeClassPair(c)
+	b, err = AllocateClassPair(c, "GoTestInstanceObserved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := b.Register()
+	defer DisposeClassPair(sub)
+
+	inst, err := NewInstance(c, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := inst.ClassName(); name != "GoTestInstance" {
+		t.Errorf("unexpected class name: %q", name)
+	}
+	extra := inst.Extra()
+	if len(extra) != 16 {
+		t.Fatalf("unexpected extra size: %d", len(extra))
+	}
+	if unsafe.Pointer(&extra[0]) != inst.IndexedIvars() {
+		t.Error("extra bytes don't match indexed ivars")
+	}
+	for i := range extra {
+		extra[i] = byte(i + 1)
+	}
+	if err = inst.SetIvar("value", int32(42)); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := inst.Copy(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := cp.GetIvar("value"); err != nil {
+		t.Error(err)
+	} else if v != int32(42) {
+		t.Errorf("unexpected ivar value in copy: %#v", v)
+	}
+	if got := (*[16]byte)(cp.IndexedIvars()); got[15] != 16 {
+		t.Errorf("extra bytes were not copied: %v", got[:])
+	}
+	cp.Dispose()
+
+	if prev := inst.SetClass(sub); prev == nil || prev.Name() != "GoTestInstance" {
+		t.Errorf("unexpected previous class: %v", prev)
+	}
+	if name := inst.ClassName(); name != "GoTestInstanceObserved" {
+		t.Errorf("unexpected class name after swizzling: %q", name)
+	}
+	if v, err := inst.GetIvar("value"); err != nil {
+		t.Error(err)
+	} else if v != int32(42) {
+		t.Errorf("unexpected ivar value after swizzling: %#v", v)
+	}
+	inst.SetClass(c)
+	inst.Dispose()
+}
+
+func TestForwarding(t *testing.T) {
+	newObject := func(c *Class) Object {
+		res, err := c.Send(RegisterSelector("new"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.(Object)
+	}
+	b, err := AllocateClassPair(GetClass("NSObject"), "GoTestForwardTarget")
+	if err != nil {
+		t.Skip(err)
+	}
+	add := RegisterSelector("add:to:")
+	err = b.AddMethod(add, func(self Object, cmd Selector, a, b int32) int32 {
+		return a + b
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scale := RegisterSelector("scale:")
+	err = b.AddMethod(scale, func(self Object, cmd Selector, v float64) float64 {
+		return v * 10
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := b.Register()
+	defer DisposeClassPair(tc)
+	target := newObject(tc)
+	defer target.Release()
+
+	b, err = AllocateClassPair(GetClass("NSObject"), "GoTestForwardProxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer := RegisterSelector("answer")
+	err = b.SetResolveInstanceMethod(func(c *Class, sel Selector) bool {
+		if !sel.Equal(answer) {
+			return false
+		}
+		return c.AddMethod(sel, func(self Object, cmd Selector) int32 {
+			return 42
+		}) == nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.SetForwardingTarget(func(self Object, sel Selector) Object {
+		if sel.Equal(add) {
+			return target
+		}
+		return Object{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forwarded []string
+	err = b.SetForwardInvocation(func(self Object, sel Selector) string {
+		if sel.Equal(scale) {
+			return "d@:d"
+		}
+		return ""
+	}, func(self Object, inv Invocation) {
+		forwarded = append(forwarded, inv.Selector().Name())
+		if inv.Target() != self {
+			t.Errorf("unexpected target: %v", inv.Target())
+		}
+		if n := inv.NumArguments(); n != 1 {
+			t.Errorf("unexpected number of arguments: %d", n)
+		}
+		v, err := inv.Argument(0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = inv.SetArgument(0, v.(float64)*2); err != nil {
+			t.Error(err)
+			return
+		}
+		if err = inv.InvokeWithTarget(target); err != nil {
+			t.Error(err)
+			return
+		}
+		res, err := inv.ReturnValue()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = inv.SetReturnValue(res.(float64) + 1); err != nil {
+			t.Error(err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc := b.Register()
+	defer DisposeClassPair(pc)
+	proxy := newObject(pc)
+	defer proxy.Release()
+
+	if res, err := proxy.Send(answer); err != nil {
+		t.Error(err)
+	} else if res != int32(42) {
+		t.Errorf("unexpected resolved result: %#v", res)
+	}
+	if m := pc.InstanceMethod("answer"); m == nil {
+		t.Error("method was not added by the resolver")
+	}
+	if !Runtime().Has(CapForwarding) {
+		t.Skip("forwarding is not supported")
+	}
+	if res, err := proxy.Send(add, 2, 3); err != nil {
+		t.Error(err)
+	} else if res != int32(5) {
+		t.Errorf("unexpected forwarded result: %#v", res)
+	}
+	if res, err := proxy.Send(scale, 1.5); err != nil {
+		t.Error(err)
+	} else if res != float64(31) {
+		t.Errorf("unexpected invocation result: %#v", res)
+	}
+	if len(forwarded) != 1 || forwarded[0] != "scale:" {
+		t.Errorf("unexpected forwarded messages: %q", forwarded)
+	}
+	if _, err := proxy.Send(RegisterSelector("missing")); err == nil {
+		t.Error("expected an error for unknown selector")
+	}
+}
+
+func TestInstanceGoValues(t *testing.T) {
+	if GetClass("NSObject") == nil {
+		t.Skip("NSObject is not available")
+	}
+	base, err := RegisterClass((*goTestDealloc)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DisposeClassPair(base)
+	c, err := RegisterClass((*goTestDeallocSub)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DisposeClassPair(c)
+	obj, err := c.CreateInstance(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcs := findGoClasses(c.class)
+	if len(gcs) != 2 {
+		t.Fatalf("unexpected number of Go classes: %d", len(gcs))
+	}
+	for _, gc := range gcs {
+		gc.value(obj)
+	}
+	cp, err := obj.Copy(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gc := range gcs {
+		if *gc.handlePtr(obj) == 0 {
+			t.Errorf("%v: value of the original object is missing", gc.typ)
+		}
+		if *gc.handlePtr(cp) != 0 {
+			t.Errorf("%v: value was copied", gc.typ)
+		}
+	}
+	cp.Dispose()
+	obj.Dispose()
+}
+
+func TestForwardingSubclass(t *testing.T) {
+	if !Runtime().Has(CapForwarding) {
+		t.Skip("forwarding is not supported")
+	}
+	b, err := AllocateClassPair(GetClass("NSObject"), "GoTestForwardSubTarget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := RegisterSelector("add:to:")
+	err = b.AddMethod(add, func(self Object, cmd Selector, a, b int32) int32 {
+		return a + b
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mul := RegisterSelector("mul:by:")
+	err = b.AddMethod(mul, func(self Object, cmd Selector, a, b int32) int32 {
+		return a * b
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := b.Register()
+	defer DisposeClassPair(tc)
+	res, err := tc.Send(RegisterSelector("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := res.(Object)
+	defer target.Release()
+
+	b, err = AllocateClassPair(GetClass("NSObject"), "GoTestForwardBase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.SetForwardingTarget(func(self Object, sel Selector) Object {
+		if sel.Equal(add) {
+			return target
+		}
+		return Object{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := RegisterSelector("half:")
+	err = b.SetForwardInvocation(func(self Object, sel Selector) string {
+		if sel.Equal(half) {
+			return "d@:d"
+		}
+		return ""
+	}, func(self Object, inv Invocation) {
+		v, err := inv.Argument(0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = inv.SetReturnValue(v.(float64) / 2); err != nil {
+			t.Error(err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := b.Register()
+	defer DisposeClassPair(base)
+
+	b, err = AllocateClassPair(base, "GoTestForwardSub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.SetForwardingTarget(func(self Object, sel Selector) Object {
+		if sel.Equal(mul) {
+			return target
+		}
+		return Object{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := b.Register()
+	defer DisposeClassPair(sub)
+	res, err = sub.Send(RegisterSelector("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := res.(Object)
+	defer proxy.Release()
+
+	for _, c := range []struct {
+		sel  Selector
+		args []interface{}
+		exp  interface{}
+	}{
+		{sel: mul, args: []interface{}{2, 3}, exp: int32(6)},
+		{sel: add, args: []interface{}{2, 3}, exp: int32(5)},
+		{sel: half, args: []interface{}{3.0}, exp: 1.5},
+	} {
+		if res, err := proxy.Send(c.sel, c.args...); err != nil {
+			t.Errorf("%s: %v", c.sel.Name(), err)
+		} else if res != c.exp {
+			t.Errorf("%s: unexpected result: %#v", c.sel.Name(), res)
+		}
+	}
+}
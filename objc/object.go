@@ -0,0 +1,59 @@
+package objc
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Object is a reference to an instance of an Objective-C class.
+type Object struct {
+	id cID
+}
+
+// IsNil checks if the object reference is nil.
+func (o Object) IsNil() bool {
+	return o.id == nil
+}
+
+// Pointer returns the raw id of the object.
+func (o Object) Pointer() unsafe.Pointer {
+	return unsafe.Pointer(o.id)
+}
+
+func (o Object) String() string {
+	if o.IsNil() {
+		return "<nil>"
+	}
+	return fmt.Sprintf("<%s %p>", o.Class(), o.Pointer())
+}
+
+// Class returns the class of an object.
+//
+// See object_getClass.
+func (o Object) Class() *Class {
+	if o.IsNil() {
+		return nil
+	}
+	c := object_getClass(o.id)
+	if c == nil {
+		return nil
+	}
+	return &Class{class: c}
+}
+
+func incPtr(p unsafe.Pointer, i uintptr) unsafe.Pointer {
+	return unsafe.Pointer(uintptr(p) + i)
+}
+
+// goString copies a zero-terminated C string.
+func goString(p unsafe.Pointer) string {
+	n := uintptr(0)
+	for *(*byte)(incPtr(p, n)) != 0 {
+		n++
+	}
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = *(*byte)(incPtr(p, uintptr(i)))
+	}
+	return string(b)
+}
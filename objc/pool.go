@@ -0,0 +1,127 @@
+package objc
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// AutoreleasePool is an autorelease pool created with PushPool.
+//
+// Pools are bound to the OS thread they were created on and must be popped
+// on the same thread in the reverse order of creation.
+type AutoreleasePool struct {
+	pool   unsafe.Pointer
+	thread uintptr
+	popped bool
+}
+
+// pools holds stacks of active autorelease pools for each OS thread.
+var pools = struct {
+	sync.Mutex
+	byThread map[uintptr][]*AutoreleasePool
+}{
+	byThread: make(map[uintptr][]*AutoreleasePool),
+}
+
+// PushPool creates a new autorelease pool and locks the calling goroutine to its OS thread.
+// The pool must be released with PopPool on the same goroutine.
+//
+// It returns an error if the runtime does not support autorelease pools, see CapAutoreleasePools.
+// The thread is not locked in this case.
+//
+// See objc_autoreleasePoolPush.
+func PushPool() (*AutoreleasePool, error) {
+	if !Runtime().Has(CapAutoreleasePools) {
+		return nil, fmt.Errorf("objc: autorelease pools are not supported by the runtime")
+	}
+	runtime.LockOSThread()
+	pool := objc_autoreleasePoolPush()
+	if pool == nil {
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("objc: cannot create autorelease pool")
+	}
+	p := &AutoreleasePool{
+		pool:   pool,
+		thread: threadID(),
+	}
+	pools.Lock()
+	pools.byThread[p.thread] = append(pools.byThread[p.thread], p)
+	pools.Unlock()
+	return p, nil
+}
+
+// PopPool releases all objects in the autorelease pool and unlocks the OS thread.
+//
+// It returns an error if the pool was already popped, if it's popped on a different
+// thread than it was created on, or if there are inner pools that were not popped yet.
+// The pool is not released in this case.
+//
+// See objc_autoreleasePoolPop.
+func PopPool(p *AutoreleasePool) error {
+	return popPool(p, false)
+}
+
+// popPool pops the autorelease pool. If force is set, inner pools that are still active
+// are popped as well, same as the runtime does, but the error about them is still returned.
+func popPool(p *AutoreleasePool, force bool) error {
+	if p == nil {
+		return fmt.Errorf("objc: pop nil autorelease pool")
+	}
+	pools.Lock()
+	defer pools.Unlock()
+	if p.popped {
+		return fmt.Errorf("objc: autorelease pool is already popped")
+	} else if tid := threadID(); tid != p.thread {
+		return fmt.Errorf("objc: autorelease pool is popped on a wrong thread: %#x, expected %#x", tid, p.thread)
+	}
+	stack := pools.byThread[p.thread]
+	i := len(stack) - 1
+	for i >= 0 && stack[i] != p {
+		i--
+	}
+	if i < 0 {
+		return fmt.Errorf("objc: unknown autorelease pool")
+	}
+	var err error
+	if n := len(stack) - 1 - i; n != 0 {
+		err = fmt.Errorf("objc: unbalanced autorelease pool pop: %d inner pools are still active", n)
+		if !force {
+			return err
+		}
+	}
+	objc_autoreleasePoolPop(p.pool)
+	for j := len(stack) - 1; j >= i; j-- {
+		stack[j].popped = true
+		stack[j] = nil
+		runtime.UnlockOSThread()
+	}
+	if stack = stack[:i]; len(stack) == 0 {
+		delete(pools.byThread, p.thread)
+	} else {
+		pools.byThread[p.thread] = stack
+	}
+	return err
+}
+
+// WithAutoreleasePool calls the function inside a new autorelease pool.
+// The goroutine is locked to its OS thread while the function runs.
+// The pool is popped even if the function panics.
+//
+// It returns an error without calling the function if the runtime does not support
+// autorelease pools, see PushPool. If the function leaves inner pools active, they are
+// popped together with the pool and an error is returned.
+func WithAutoreleasePool(fnc func()) (err error) {
+	p, err := PushPool()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if perr := popPool(p, true); perr != nil && err == nil {
+			err = perr
+		}
+	}()
+	fnc()
+	return nil
+}
@@ -0,0 +1,179 @@
+package objc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dennwc/go-apple/objc/encoding"
+)
+
+// Property describes a declared property of a class.
+type Property struct {
+	prop cProp
+}
+
+func (p *Property) Valid() bool {
+	return p != nil && p.prop != nil
+}
+
+func (p Property) String() string {
+	if !p.Valid() {
+		return "<nil>"
+	}
+	return p.Name() + " " + p.Attributes()
+}
+
+// Name returns the name of a property.
+//
+// See property_getName.
+func (p Property) Name() string {
+	if !p.Valid() {
+		return ""
+	}
+	return property_getName(p.prop)
+}
+
+// Attributes returns the attribute string of a property.
+// It may be empty if the runtime does not provide property attributes.
+//
+// See property_getAttributes.
+func (p Property) Attributes() string {
+	if !p.Valid() {
+		return ""
+	}
+	return property_getAttributes(p.prop)
+}
+
+// ParseAttributes parses the attribute string of a property.
+func (p Property) ParseAttributes() (*PropertyAttributes, error) {
+	if !p.Valid() {
+		return nil, fmt.Errorf("objc: invalid property")
+	}
+	a, err := ParsePropertyAttributes(p.Attributes())
+	if err != nil {
+		return nil, fmt.Errorf("objc: property %q: %v", p.Name(), err)
+	}
+	return a, nil
+}
+
+// Getter returns the name of the getter method of a property.
+func (p Property) Getter() string {
+	if a, err := p.ParseAttributes(); err == nil && a.Getter != "" {
+		return a.Getter
+	}
+	return p.Name()
+}
+
+// Setter returns the name of the setter method of a property.
+// It returns an empty string for read-only properties.
+func (p Property) Setter() string {
+	a, err := p.ParseAttributes()
+	if err == nil {
+		if a.ReadOnly {
+			return ""
+		} else if a.Setter != "" {
+			return a.Setter
+		}
+	}
+	name := p.Name()
+	if name == "" {
+		return ""
+	}
+	return "set" + strings.ToUpper(name[:1]) + name[1:] + ":"
+}
+
+// PropertyAttributes is a parsed attribute string of a declared property.
+//
+// See https://developer.apple.com/library/archive/documentation/Cocoa/Conceptual/ObjCRuntimeGuide/Articles/ocrtPropertyIntrospection.html
+type PropertyAttributes struct {
+	Type      string // type encoding of the property
+	ReadOnly  bool   // readonly
+	Copy      bool   // copy
+	Retain    bool   // retain or strong
+	Weak      bool   // weak
+	NonAtomic bool   // nonatomic
+	Dynamic   bool   // @dynamic
+	Getter    string // custom getter name, if any
+	Setter    string // custom setter name, if any
+	Ivar      string // name of the backing instance variable, if any
+}
+
+// ParseType parses the type encoding of the property.
+func (a *PropertyAttributes) ParseType() (encoding.Type, error) {
+	return encoding.Parse(a.Type)
+}
+
+// ParsePropertyAttributes parses a property attribute string returned by property_getAttributes.
+//
+// Unknown attributes are ignored.
+func ParsePropertyAttributes(s string) (*PropertyAttributes, error) {
+	if !strings.HasPrefix(s, "T") {
+		return nil, fmt.Errorf("invalid attribute string: %q", s)
+	}
+	var a PropertyAttributes
+	for _, attr := range strings.Split(s, ",") {
+		if attr == "" {
+			return nil, fmt.Errorf("invalid attribute string: %q", s)
+		}
+		val := attr[1:]
+		switch attr[0] {
+		case 'T':
+			a.Type = val
+		case 'R':
+			a.ReadOnly = true
+		case 'C':
+			a.Copy = true
+		case '&':
+			a.Retain = true
+		case 'W':
+			a.Weak = true
+		case 'N':
+			a.NonAtomic = true
+		case 'D':
+			a.Dynamic = true
+		case 'G':
+			a.Getter = val
+		case 'S':
+			a.Setter = val
+		case 'V':
+			a.Ivar = val
+		}
+	}
+	if a.Type == "" {
+		return nil, fmt.Errorf("no type in attribute string: %q", s)
+	}
+	return &a, nil
+}
+
+// Properties returns properties declared by the class.
+// Properties declared by superclasses are not included.
+//
+// See class_copyPropertyList.
+func (c *Class) Properties() []Property {
+	if !c.Valid() {
+		return nil
+	}
+	list := class_copyPropertyList(c.class)
+	if list == nil {
+		return nil
+	}
+	out := make([]Property, 0, len(list))
+	for _, p := range list {
+		out = append(out, Property{prop: p})
+	}
+	return out
+}
+
+// Property returns a property with a given name.
+//
+// See class_getProperty.
+func (c *Class) Property(name string) *Property {
+	if !c.Valid() {
+		return nil
+	}
+	p := class_getProperty(c.class, name)
+	if p == nil {
+		return nil
+	}
+	return &Property{prop: p}
+}
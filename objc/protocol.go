@@ -0,0 +1,129 @@
+package objc
+
+// GetProtocol returns a specified protocol.
+//
+// See objc_getProtocol.
+func GetProtocol(name string) *Protocol {
+	p := objc_getProtocol(name)
+	if p == nil {
+		return nil
+	}
+	return &Protocol{proto: p}
+}
+
+// ListProtocols returns all protocols known to the runtime.
+//
+// See objc_copyProtocolList.
+func ListProtocols() []Protocol {
+	return protocolList(objc_copyProtocolList())
+}
+
+// protocolList converts the protocol list returned by the runtime.
+func protocolList(list []*cProtocol) []Protocol {
+	if list == nil {
+		return nil
+	}
+	out := make([]Protocol, 0, len(list))
+	for _, p := range list {
+		out = append(out, Protocol{proto: p})
+	}
+	return out
+}
+
+// Protocol is a declaration of methods that a class may implement.
+type Protocol struct {
+	proto *cProtocol
+}
+
+func (p *Protocol) Valid() bool {
+	return p != nil && p.proto != nil
+}
+
+func (p Protocol) String() string {
+	if !p.Valid() {
+		return "<nil>"
+	}
+	return p.Name()
+}
+
+// Name returns the name of a protocol.
+//
+// See protocol_getName.
+func (p Protocol) Name() string {
+	if !p.Valid() {
+		return ""
+	}
+	return protocol_getName(p.proto)
+}
+
+// Equal checks if two protocols are the same.
+//
+// See protocol_isEqual.
+func (p *Protocol) Equal(p2 *Protocol) bool {
+	if !p.Valid() || !p2.Valid() {
+		return p.Valid() == p2.Valid()
+	}
+	return protocol_isEqual(p.proto, p2.proto)
+}
+
+// ConformsTo checks if a protocol conforms to another protocol.
+//
+// See protocol_conformsToProtocol.
+func (p *Protocol) ConformsTo(p2 *Protocol) bool {
+	if !p.Valid() || !p2.Valid() {
+		return false
+	}
+	return protocol_conformsToProtocol(p.proto, p2.proto)
+}
+
+// Protocols returns protocols adopted by the protocol.
+//
+// See protocol_copyProtocolList.
+func (p *Protocol) Protocols() []Protocol {
+	if !p.Valid() {
+		return nil
+	}
+	return protocolList(protocol_copyProtocolList(p.proto))
+}
+
+// MethodDescription describes a method declared by a protocol.
+type MethodDescription struct {
+	Name  string // selector name
+	Types string // type encoding of the method
+}
+
+// MethodDescriptions returns descriptions of methods declared by the protocol.
+// Methods declared by adopted protocols are not included.
+//
+// The required flag selects required or optional methods, and the instance flag
+// selects instance or class methods.
+//
+// See protocol_copyMethodDescriptionList.
+func (p *Protocol) MethodDescriptions(required, instance bool) []MethodDescription {
+	if !p.Valid() {
+		return nil
+	}
+	return protocol_copyMethodDescriptionList(p.proto, required, instance)
+}
+
+// Protocols returns protocols adopted by the class.
+// Protocols adopted by superclasses are not included.
+//
+// See class_copyProtocolList.
+func (c *Class) Protocols() []Protocol {
+	if !c.Valid() {
+		return nil
+	}
+	return protocolList(class_copyProtocolList(c.class))
+}
+
+// ConformsTo checks if a class conforms to a given protocol.
+// Superclasses are not checked.
+//
+// See class_conformsToProtocol.
+func (c *Class) ConformsTo(p *Protocol) bool {
+	if !c.Valid() || !p.Valid() {
+		return false
+	}
+	return class_conformsToProtocol(c.class, p.proto)
+}
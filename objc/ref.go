@@ -0,0 +1,120 @@
+package objc
+
+import (
+	"runtime"
+	"sync"
+)
+
+// sendIfResponds sends a message without arguments if the object implements it.
+func (o Object) sendIfResponds(name string) {
+	sel := RegisterSelector(name)
+	if class_getInstanceMethod(object_getClass(o.id), sel.sel) == nil {
+		return
+	}
+	_, _ = o.Send(sel)
+}
+
+// Retain increments the reference count of the object and returns it.
+// Objects that do not implement reference counting are not affected.
+//
+// See objc_retain.
+func (o Object) Retain() Object {
+	if o.IsNil() {
+		return o
+	}
+	if !objc_retain(o.id) {
+		o.sendIfResponds("retain")
+	}
+	return o
+}
+
+// Release decrements the reference count of the object.
+// Objects that do not implement reference counting are not affected.
+//
+// See objc_release.
+func (o Object) Release() {
+	if o.IsNil() {
+		return
+	}
+	if !objc_release(o.id) {
+		o.sendIfResponds("release")
+	}
+}
+
+// Autorelease adds the object to the current autorelease pool and returns it.
+// Objects that do not implement reference counting are not affected.
+//
+// See objc_autorelease.
+func (o Object) Autorelease() Object {
+	if o.IsNil() {
+		return o
+	}
+	if !objc_autorelease(o.id) {
+		o.sendIfResponds("autorelease")
+	}
+	return o
+}
+
+// Ref is an owning reference to an object.
+//
+// The object is released when Close is called or when the Ref is garbage collected.
+// Calling Close explicitly is preferred, since finalizers may run late or not at all.
+// The Ref must be kept reachable while the object is in use, see runtime.KeepAlive.
+type Ref struct {
+	mu  sync.Mutex
+	obj Object
+}
+
+func newRef(o Object) *Ref {
+	r := &Ref{obj: o}
+	if !o.IsNil() {
+		runtime.SetFinalizer(r, (*Ref).Close)
+	}
+	return r
+}
+
+// Adopt takes ownership of a +1 reference to the object, such as the one
+// returned by alloc, new, copy or retain. The object is not retained again.
+func Adopt(o Object) *Ref {
+	return newRef(o)
+}
+
+// Retain retains the object and returns an owning reference to it.
+// It should be used for +0 references, such as the ones returned by most methods.
+func Retain(o Object) *Ref {
+	return newRef(o.Retain())
+}
+
+// Object returns the referenced object. It returns nil if the reference was closed.
+func (r *Ref) Object() Object {
+	if r == nil {
+		return Object{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.obj
+}
+
+// Detach gives up the ownership without releasing the object and returns it.
+// The caller becomes responsible for releasing the returned +1 reference.
+func (r *Ref) Detach() Object {
+	if r == nil {
+		return Object{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o := r.obj
+	r.obj = Object{}
+	runtime.SetFinalizer(r, nil)
+	return o
+}
+
+// Close releases the object. It is safe to call Close multiple times.
+func (r *Ref) Close() error {
+	if r == nil {
+		return nil
+	}
+	o := r.Detach()
+	o.Release()
+	return nil
+}
@@ -0,0 +1,339 @@
+package objc
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// handleIvar is the name of an instance variable that holds a handle of a Go value bound to the object.
+const handleIvar = "_goHandle"
+
+// goClass describes a class registered from a Go struct type.
+type goClass struct {
+	typ    reflect.Type // struct type
+	field  int          // index of the embedded Object field
+	handle uintptr      // offset of the handle ivar
+}
+
+var goClasses = struct {
+	sync.RWMutex
+	byClass map[cClass]*goClass
+}{
+	byClass: make(map[cClass]*goClass),
+}
+
+// goValuesMu serializes creation of Go values bound to objects.
+var goValuesMu sync.Mutex
+
+// findGoClass finds a Go class definition for a given class or its superclasses.
+func findGoClass(c cClass) *goClass {
+	goClasses.RLock()
+	defer goClasses.RUnlock()
+	for ; c != nil; c = class_getSuperclass(c) {
+		if gc := goClasses.byClass[c]; gc != nil {
+			return gc
+		}
+	}
+	return nil
+}
+
+// findGoClasses finds Go class definitions for a given class and its superclasses.
+func findGoClasses(c cClass) []*goClass {
+	goClasses.RLock()
+	defer goClasses.RUnlock()
+	var out []*goClass
+	for ; c != nil; c = class_getSuperclass(c) {
+		if gc := goClasses.byClass[c]; gc != nil {
+			out = append(out, gc)
+		}
+	}
+	return out
+}
+
+// handlePtr returns a pointer to the handle ivar of the object.
+func (gc *goClass) handlePtr(self Object) *uintptr {
+	return (*uintptr)(incPtr(self.Pointer(), gc.handle))
+}
+
+// value returns a Go value bound to the object. The value is created on the first access.
+func (gc *goClass) value(self Object) reflect.Value {
+	p := gc.handlePtr(self)
+	goValuesMu.Lock()
+	defer goValuesMu.Unlock()
+	if *p != 0 {
+		return handleValue(*p).(reflect.Value)
+	}
+	v := reflect.New(gc.typ)
+	v.Elem().Field(gc.field).Set(reflect.ValueOf(self))
+	*p = newHandle(v)
+	return v
+}
+
+// release removes the Go value bound to the object.
+func (gc *goClass) release(self Object) {
+	p := gc.handlePtr(self)
+	goValuesMu.Lock()
+	defer goValuesMu.Unlock()
+	if *p != 0 {
+		deleteHandle(*p)
+		*p = 0
+	}
+}
+
+// wrapMethod converts a Go method to a function that can be used as a method implementation.
+func (gc *goClass) wrapMethod(m reflect.Method) reflect.Value {
+	mt := m.Type
+	in := []reflect.Type{objectType, selectorType}
+	for i := 1; i < mt.NumIn(); i++ {
+		in = append(in, mt.In(i))
+	}
+	var out []reflect.Type
+	for i := 0; i < mt.NumOut(); i++ {
+		out = append(out, mt.Out(i))
+	}
+	ft := reflect.FuncOf(in, out, mt.IsVariadic())
+	return reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+		self := args[0].Interface().(Object)
+		// replace the selector with the receiver
+		args[1] = gc.value(self)
+		return m.Func.Call(args[1:])
+	})
+}
+
+// GoValue returns a Go value bound to an instance of a class registered with RegisterClass.
+// The value is a pointer to the struct type used for registration.
+// It returns nil if the class of the object was not registered from Go.
+func (o Object) GoValue() interface{} {
+	if o.IsNil() {
+		return nil
+	}
+	gc := findGoClass(object_getClass(o.id))
+	if gc == nil {
+		return nil
+	}
+	return gc.value(o).Interface()
+}
+
+// RegisterClass creates and registers a new class from a Go struct type.
+//
+// The struct must embed Object with a tag that describes the class in the following format:
+//
+//	type GoDelegate struct {
+//		objc.Object `objc:"GoDelegate : NSObject <NSCopying, NSCoding>"`
+//	}
+//
+// Protocols are optional. If the superclass is a name of a protocol, the class is derived from
+// NSObject and adopts the protocol, as in wrappers emitted by the generator. Exported methods of the struct become instance methods of the class.
+// Method names are converted to selectors by lowercasing the first letter and replacing
+// underscores with colons, thus method Foo_bar_ is registered as "foo:bar:". A trailing
+// colon is added if the method has one argument more than the selector implies.
+// See ClassBuilder.AddMethod for supported argument types.
+//
+// Selectors can be set explicitly with a Selectors method that returns selectors by method names,
+// for example {"URLForKey": "URLForKey:"}. It's called on a zero value of the struct.
+// Methods of the embedded Object are not registered. Methods declared on the struct with
+// the same names as Object methods are rejected, unless their selectors are set explicitly.
+//
+// A Go value is created for each object of the class on the first method call and can
+// be accessed with Object.GoValue. If the superclass implements dealloc, the value is
+// released when the object is deallocated; a Dealloc method of the struct is called before that.
+// The superclass may be registered from Go as well, in which case its methods receive a separate
+// value of its own struct type.
+func RegisterClass(v interface{}) (*Class, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("objc: expected a struct, got %T", v)
+	}
+	gc := &goClass{typ: t, field: -1}
+	var tag string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type == objectType {
+			gc.field, tag = i, f.Tag.Get("objc")
+			break
+		}
+	}
+	if gc.field < 0 {
+		return nil, fmt.Errorf("objc: %v must embed objc.Object", t)
+	}
+	name, superName, protos, err := parseClassTag(tag)
+	if err != nil {
+		return nil, fmt.Errorf("objc: %v: %v", t, err)
+	}
+	super := GetClass(superName)
+	if super == nil && len(protos) == 0 && GetProtocol(superName) != nil {
+		// "Name : Proto" is the same as "Name : NSObject <Proto>"
+		super, protos = GetClass("NSObject"), []string{superName}
+	}
+	if super == nil {
+		return nil, fmt.Errorf("objc: %v: superclass %q not found", t, superName)
+	}
+	b, err := AllocateClassPair(super, name)
+	if err != nil {
+		return nil, err
+	}
+	if err = gc.build(b, super, protos); err != nil {
+		DisposeClassPair(b.Class())
+		return nil, fmt.Errorf("objc: %v: %v", t, err)
+	}
+	c := b.Register()
+	gc.handle = c.Ivar(handleIvar).Offset()
+	goClasses.Lock()
+	goClasses.byClass[c.class] = gc
+	goClasses.Unlock()
+	return c, nil
+}
+
+// build adds protocols, ivars and methods to the class.
+func (gc *goClass) build(b *ClassBuilder, super *Class, protos []string) error {
+	for _, name := range protos {
+		p := GetProtocol(name)
+		if p == nil {
+			return fmt.Errorf("protocol %q not found", name)
+		}
+		if err := b.AddProtocol(p); err != nil {
+			return err
+		}
+	}
+	if err := b.AddIvar(handleIvar, "^v"); err != nil {
+		return err
+	}
+	pt := reflect.PtrTo(gc.typ)
+	var sels map[string]string
+	v, hasSels := reflect.New(gc.typ).Interface().(selectorMap)
+	if hasSels {
+		sels = v.Selectors()
+		for name := range sels {
+			if _, ok := pt.MethodByName(name); !ok {
+				return fmt.Errorf("selector for unknown method %s", name)
+			}
+		}
+	}
+	for i := 0; i < pt.NumMethod(); i++ {
+		m := pt.Method(i)
+		if promotedFromObject(gc.typ, m.Name) {
+			continue
+		} else if m.Name == "Dealloc" && m.Type.NumIn() == 1 {
+			continue
+		} else if m.Name == "Selectors" && hasSels {
+			continue
+		}
+		sel, ok := sels[m.Name]
+		if _, shadows := objectType.MethodByName(m.Name); shadows && !ok {
+			return fmt.Errorf("method %s shadows objc.Object.%s, set its selector explicitly to register it", m.Name, m.Name)
+		}
+		var err error
+		if ok {
+			err = checkSelector(m.Name, sel, m.Type.NumIn()-1)
+		} else {
+			sel, err = methodSelector(m.Name, m.Type.NumIn()-1)
+		}
+		if err != nil {
+			return err
+		}
+		gm, err := newGoMethodValue(gc.wrapMethod(m))
+		if err != nil {
+			return fmt.Errorf("method %s: %v", m.Name, err)
+		}
+		if err = addGoMethod(b.class.class, RegisterSelector(sel), gm); err != nil {
+			return err
+		}
+	}
+	dealloc := RegisterSelector("dealloc")
+	if class_getInstanceMethod(super.class, dealloc.sel) == nil {
+		return nil
+	}
+	userDealloc, hasDealloc := pt.MethodByName("Dealloc")
+	gm, err := newGoMethod(func(self Object, cmd Selector) {
+		if hasDealloc && userDealloc.Type.NumIn() == 1 {
+			userDealloc.Func.Call([]reflect.Value{gc.value(self)})
+		}
+		gc.release(self)
+		var a callArgs
+		imp := class_getMethodImplementation(super.class, cmd.sel)
+		// exceptions must not propagate from dealloc, thus they are ignored
+		_, _ = callInt(imp, self.Pointer(), unsafe.Pointer(cmd.sel), &a)
+	})
+	if err != nil {
+		return err
+	}
+	return addGoMethod(b.class.class, dealloc, gm)
+}
+
+// selectorMap is implemented by structs that set selectors of their methods explicitly, see RegisterClass.
+type selectorMap interface {
+	Selectors() map[string]string
+}
+
+// promotedFromObject checks if the method of the struct or its pointer is promoted from the embedded Object.
+//
+// Object methods have value receivers, thus promoted ones are in the method set of the struct itself.
+// There they are wrappers generated by the compiler, unlike methods declared on the struct.
+func promotedFromObject(t reflect.Type, name string) bool {
+	if _, ok := objectType.MethodByName(name); !ok {
+		return false
+	}
+	m, ok := t.MethodByName(name)
+	if !ok {
+		// declared with a pointer receiver
+		return false
+	}
+	f := runtime.FuncForPC(m.Func.Pointer())
+	if f == nil {
+		return false
+	}
+	file, _ := f.FileLine(f.Entry())
+	return file == "<autogenerated>"
+}
+
+// methodSelector converts a Go method name to a selector name with a given number of arguments.
+func methodSelector(name string, nargs int) (string, error) {
+	sel := strings.ToLower(name[:1]) + name[1:]
+	sel = strings.Replace(sel, "_", ":", -1)
+	if n := strings.Count(sel, ":"); n+1 == nargs && !strings.HasSuffix(sel, ":") {
+		sel += ":"
+	}
+	if err := checkSelector(name, sel, nargs); err != nil {
+		return "", err
+	}
+	return sel, nil
+}
+
+// checkSelector checks that the selector of the method matches a given number of arguments.
+func checkSelector(name, sel string, nargs int) error {
+	if n := strings.Count(sel, ":"); n != nargs {
+		return fmt.Errorf("method %s: selector %q doesn't match %d arguments", name, sel, nargs)
+	}
+	return nil
+}
+
+// parseClassTag parses a class description in the "Name : Super <Proto1, Proto2>" format.
+func parseClassTag(tag string) (name, super string, protos []string, _ error) {
+	s := tag
+	if i := strings.IndexByte(s, '<'); i >= 0 {
+		j := strings.LastIndexByte(s, '>')
+		if j < i || strings.TrimSpace(s[j+1:]) != "" {
+			return "", "", nil, fmt.Errorf("invalid class tag: %q", tag)
+		}
+		for _, p := range strings.Split(s[i+1:j], ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				protos = append(protos, p)
+			}
+		}
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		name, super = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	}
+	if name == "" || super == "" || strings.ContainsAny(name+super, " :") {
+		return "", "", nil, fmt.Errorf("invalid class tag: %q", tag)
+	}
+	return name, super, protos, nil
+}
@@ -0,0 +1,353 @@
+//go:build objcfake
+// +build objcfake
+
+package objc
+
+import (
+	"math/bits"
+	"sync"
+	"unsafe"
+)
+
+// Root classes of the fake runtime. Object mimics the root class of GCC libobjc
+// and NSObject mimics the one from Foundation. Both implement allocation, reference counting
+// and basic introspection. The Protocol class is defined for compatibility with GNU runtimes.
+// NSMethodSignature and NSInvocation are defined for message forwarding, see forward_fake.go.
+// NSObject and NSCopying are the only protocols, since protocols can't be declared.
+func init() {
+	object := newFakeRoot("Object")
+	nsobject := newFakeRoot("NSObject")
+	newFakeInvocationClasses(nsobject)
+	// the NSObject protocol is adopted by the root class, same as in Foundation
+	proto := &fakeProtocol{name: "NSObject"}
+	fakeRuntime.Lock()
+	fakeRuntime.protocols[proto.name] = proto
+	fakeRuntime.protocols["NSCopying"] = &fakeProtocol{name: "NSCopying"}
+	fakeRuntime.Unlock()
+	class_addProtocol(nsobject, proto)
+	objc_registerClassPair(objc_allocateClassPair(object, "Protocol"))
+}
+
+// newFakeRoot creates and registers a root class with basic methods.
+func newFakeRoot(name string) cClass {
+	c := objc_allocateClassPair(nil, name)
+	const ptrSize = unsafe.Sizeof(uintptr(0))
+	class_addIvar(c, "isa", ptrSize, uint8(bits.TrailingZeros(uint(ptrSize))), "#")
+
+	addFakeMethod(c.isa, "alloc", func(self Object, cmd Selector) Object {
+		return Object{id: fakeAlloc(cClass(self.Pointer()), 0)}
+	})
+	addFakeMethod(c.isa, "new", func(self Object, cmd Selector) Object {
+		obj := Object{id: fakeAlloc(cClass(self.Pointer()), 0)}
+		res, err := obj.Send(RegisterSelector("init"))
+		if err != nil {
+			panic(err)
+		}
+		return res.(Object)
+	})
+	addFakeMethod(c.isa, "class", func(self Object, cmd Selector) *Class {
+		return &Class{class: cClass(self.Pointer())}
+	})
+
+	addFakeMethod(c, "init", func(self Object, cmd Selector) Object {
+		return self
+	})
+	addFakeMethod(c, "self", func(self Object, cmd Selector) Object {
+		return self
+	})
+	addFakeMethod(c, "class", func(self Object, cmd Selector) *Class {
+		return self.Class()
+	})
+	addFakeMethod(c, "superclass", func(self Object, cmd Selector) *Class {
+		return self.Class().GetSuperclass()
+	})
+	addFakeMethod(c, "isEqual:", func(self Object, cmd Selector, o Object) bool {
+		return self == o
+	})
+	addFakeMethod(c, "hash", func(self Object, cmd Selector) uintptr {
+		return uintptr(self.Pointer())
+	})
+	addFakeMethod(c, "respondsToSelector:", func(self Object, cmd Selector, sel Selector) bool {
+		return class_getInstanceMethod(object_getClass(self.id), sel.sel) != nil
+	})
+	addFakeMethod(c, "isKindOfClass:", func(self Object, cmd Selector, c *Class) bool {
+		return self.Class().IsSubclassOf(c)
+	})
+	addFakeMethod(c, "isMemberOfClass:", func(self Object, cmd Selector, c *Class) bool {
+		return c.Valid() && object_getClass(self.id) == c.class
+	})
+	addFakeMethod(c, "retain", func(self Object, cmd Selector) Object {
+		fakeRetain(self.id)
+		return self
+	})
+	addFakeMethod(c, "release", func(self Object, cmd Selector) {
+		fakeRelease(self.id)
+	})
+	// autorelease pools are not supported, thus autoreleased objects are never released
+	addFakeMethod(c, "autorelease", func(self Object, cmd Selector) Object {
+		return self
+	})
+	addFakeMethod(c, "retainCount", func(self Object, cmd Selector) uintptr {
+		return fakeRetainCount(self.id)
+	})
+	addFakeMethod(c, "dealloc", func(self Object, cmd Selector) {
+		fakeDispose(self.id)
+	})
+
+	objc_registerClassPair(c)
+	return c
+}
+
+// addFakeMethod adds a method implemented in Go that is called directly by the fake runtime.
+func addFakeMethod(c cClass, name string, fnc interface{}) {
+	m, err := newGoMethod(fnc)
+	if err != nil {
+		panic(err)
+	}
+	class_addMethod(c, sel_registerName(name), newFakeIMP(m), m.types())
+}
+
+// fakeObjects holds reference counts of objects allocated by the fake runtime.
+// Objects that are not listed here, such as classes, are never deallocated.
+var fakeObjects = struct {
+	sync.Mutex
+	byID map[cID]*fakeObjectState
+}{
+	byID: make(map[cID]*fakeObjectState),
+}
+
+type fakeObjectState struct {
+	size         uintptr // allocated bytes, including extra bytes
+	refs         uintptr
+	deallocating bool
+	weak         map[*cID]struct{} // weak locations that refer to the object
+}
+
+// fakeAlloc allocates a zeroed instance of a class with a given number of extra bytes.
+// The caller owns the returned reference.
+func fakeAlloc(c cClass, extra uintptr) cID {
+	size := fakeAlignedSize(c) + extra
+	obj := cID(malloc(size))
+	obj.isa = c
+	fakeObjects.Lock()
+	fakeObjects.byID[obj] = &fakeObjectState{size: size, refs: 1}
+	fakeObjects.Unlock()
+	return obj
+}
+
+// fakeAlignedSize returns the instance size of the class rounded up to the pointer size.
+// Extra bytes of the instance start at this offset.
+func fakeAlignedSize(c cClass) uintptr {
+	const ptrSize = unsafe.Sizeof(uintptr(0))
+	return (class_getInstanceSize(c) + ptrSize - 1) &^ (ptrSize - 1)
+}
+
+func class_createInstance(c cClass, extra uintptr) cID {
+	if c == nil {
+		return nil
+	}
+	return fakeAlloc(c, extra)
+}
+
+func object_getIndexedIvars(obj cID) unsafe.Pointer {
+	if obj == nil {
+		return nil
+	}
+	return incPtr(unsafe.Pointer(obj), fakeAlignedSize(obj.isa))
+}
+
+// object_copy copies the memory of the object into a new instance of the same class.
+// Objects that were not allocated by the fake runtime can't be copied.
+func object_copy(obj cID, extra uintptr) cID {
+	fakeObjects.Lock()
+	st := fakeObjects.byID[obj]
+	fakeObjects.Unlock()
+	if st == nil {
+		return nil
+	}
+	cp := fakeAlloc(obj.isa, extra)
+	n := fakeAlignedSize(obj.isa) + extra
+	if n > st.size {
+		n = st.size
+	}
+	copy((*[1 << 28]byte)(unsafe.Pointer(cp))[:n:n], (*[1 << 28]byte)(unsafe.Pointer(obj))[:n:n])
+	return cp
+}
+
+func object_dispose(obj cID) {
+	fakeDispose(obj)
+}
+
+func object_setClass(obj cID, c cClass) cClass {
+	if obj == nil {
+		return nil
+	}
+	old := obj.isa
+	obj.isa = c
+	return old
+}
+
+func object_getClassName(obj cID) string {
+	if obj == nil || obj.isa == nil {
+		return "nil"
+	}
+	return obj.isa.name
+}
+
+func fakeRetain(obj cID) {
+	fakeObjects.Lock()
+	defer fakeObjects.Unlock()
+	if st := fakeObjects.byID[obj]; st != nil {
+		st.refs++
+	}
+}
+
+func fakeRetainCount(obj cID) uintptr {
+	fakeObjects.Lock()
+	defer fakeObjects.Unlock()
+	if st := fakeObjects.byID[obj]; st != nil {
+		return st.refs
+	}
+	// objects that are never deallocated
+	return ^uintptr(0)
+}
+
+// fakeRelease decrements the reference count and sends dealloc when it reaches zero.
+func fakeRelease(obj cID) {
+	fakeObjects.Lock()
+	st := fakeObjects.byID[obj]
+	if st == nil || st.deallocating {
+		fakeObjects.Unlock()
+		return
+	}
+	st.refs--
+	if st.refs != 0 {
+		fakeObjects.Unlock()
+		return
+	}
+	st.deallocating = true
+	fakeObjects.Unlock()
+
+	sel := sel_registerName("dealloc")
+	var a callArgs
+	// exceptions must not propagate from dealloc, thus they are ignored
+	_, _ = callInt(msgLookup(obj, sel), unsafe.Pointer(obj), unsafe.Pointer(sel), &a)
+}
+
+// fakeDispose clears weak references to the object, removes its associations and frees the memory.
+func fakeDispose(obj cID) {
+	fakeObjects.Lock()
+	st := fakeObjects.byID[obj]
+	if st == nil {
+		fakeObjects.Unlock()
+		return
+	}
+	for loc := range st.weak {
+		*loc = nil
+	}
+	delete(fakeObjects.byID, obj)
+	fakeObjects.Unlock()
+
+	objc_removeAssociatedObjects(obj)
+	free(unsafe.Pointer(obj))
+}
+
+// objc_initWeak initializes a weak location. Weak references are always supported by the fake runtime.
+func objc_initWeak(loc *cID, obj cID) bool {
+	*loc = nil
+	objc_storeWeak(loc, obj)
+	return true
+}
+
+func objc_storeWeak(loc *cID, obj cID) {
+	fakeObjects.Lock()
+	defer fakeObjects.Unlock()
+	if st := fakeObjects.byID[*loc]; st != nil {
+		delete(st.weak, loc)
+	}
+	if st := fakeObjects.byID[obj]; st != nil {
+		if st.deallocating {
+			obj = nil
+		} else {
+			if st.weak == nil {
+				st.weak = make(map[*cID]struct{})
+			}
+			st.weak[loc] = struct{}{}
+		}
+	}
+	*loc = obj
+}
+
+func objc_loadWeakRetained(loc *cID) cID {
+	fakeObjects.Lock()
+	defer fakeObjects.Unlock()
+	obj := *loc
+	if st := fakeObjects.byID[obj]; st != nil {
+		if st.deallocating {
+			return nil
+		}
+		st.refs++
+	}
+	return obj
+}
+
+func objc_destroyWeak(loc *cID) {
+	objc_storeWeak(loc, nil)
+}
+
+type fakeAssociation struct {
+	val      cID
+	retained bool
+}
+
+// fakeAssociations holds associated objects of all objects.
+var fakeAssociations = struct {
+	sync.Mutex
+	byID map[cID]map[unsafe.Pointer]fakeAssociation
+}{
+	byID: make(map[cID]map[unsafe.Pointer]fakeAssociation),
+}
+
+// objc_setAssociatedObject associates an object. Associated objects are always supported by the fake runtime.
+// Objects associated with a copy policy are retained instead.
+func objc_setAssociatedObject(obj cID, key unsafe.Pointer, val cID, policy uintptr) bool {
+	retained := AssociationPolicy(policy) != AssociationAssign
+	if val != nil && retained {
+		Object{id: val}.Retain()
+	}
+	fakeAssociations.Lock()
+	m := fakeAssociations.byID[obj]
+	old, ok := m[key]
+	if val == nil {
+		delete(m, key)
+	} else {
+		if m == nil {
+			m = make(map[unsafe.Pointer]fakeAssociation)
+			fakeAssociations.byID[obj] = m
+		}
+		m[key] = fakeAssociation{val: val, retained: retained}
+	}
+	fakeAssociations.Unlock()
+	if ok && old.retained {
+		Object{id: old.val}.Release()
+	}
+	return true
+}
+
+func objc_getAssociatedObject(obj cID, key unsafe.Pointer) cID {
+	fakeAssociations.Lock()
+	defer fakeAssociations.Unlock()
+	return fakeAssociations.byID[obj][key].val
+}
+
+func objc_removeAssociatedObjects(obj cID) {
+	fakeAssociations.Lock()
+	m := fakeAssociations.byID[obj]
+	delete(fakeAssociations.byID, obj)
+	fakeAssociations.Unlock()
+	for _, a := range m {
+		if a.retained {
+			Object{id: a.val}.Release()
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package objc
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Flavor is an implementation of the Objective-C runtime.
+type Flavor int
+
+const (
+	FlavorUnknown Flavor = iota
+	FlavorApple          // Apple objc4
+	FlavorGNUstep        // GNUstep libobjc2
+	FlavorGCC            // GCC libobjc
+	FlavorFake           // runtime implemented in Go, see the objcfake build tag
+)
+
+func (f Flavor) String() string {
+	switch f {
+	case FlavorApple:
+		return "apple"
+	case FlavorGNUstep:
+		return "gnustep"
+	case FlavorGCC:
+		return "gcc"
+	case FlavorFake:
+		return "fake"
+	}
+	return "unknown"
+}
+
+// Capability is a set of optional runtime features.
+type Capability uint
+
+const (
+	CapNonFragileIvars   Capability = 1 << iota // instance variable offsets are resolved at runtime
+	CapBlocks                                   // blocks runtime, see NewBlock
+	CapARC                                      // ARC entry points, such as objc_retain and objc_release
+	CapWeak                                     // weak references, see NewWeak
+	CapTypedSelectors                           // selectors carry type encodings, see RegisterTypedSelector
+	CapAssociatedObjects                        // associated objects, see Object.SetAssociatedObject
+	CapAutoreleasePools                         // autorelease pools, see PushPool
+	CapImages                                   // image introspection, see ImageNames
+	CapForwarding                               // message forwarding with NSInvocation, see ClassBuilder.SetForwardInvocation
+	CapExceptions                               // Objective-C exceptions are caught and returned as *Exception, see Object.TrySend
+)
+
+var capNames = map[Capability]string{
+	CapNonFragileIvars:   "nonfragile-ivars",
+	CapBlocks:            "blocks",
+	CapARC:               "arc",
+	CapWeak:              "weak",
+	CapTypedSelectors:    "typed-selectors",
+	CapAssociatedObjects: "associated-objects",
+	CapAutoreleasePools:  "autorelease-pools",
+	CapImages:            "images",
+	CapForwarding:        "forwarding",
+	CapExceptions:        "exceptions",
+}
+
+// Has checks if all given capabilities are in the set.
+func (c Capability) Has(c2 Capability) bool {
+	return c&c2 == c2
+}
+
+func (c Capability) String() string {
+	var names []string
+	for i := uint(0); c>>i != 0; i++ {
+		bit := Capability(1) << i
+		if !c.Has(bit) {
+			continue
+		}
+		name, ok := capNames[bit]
+		if !ok {
+			name = fmt.Sprintf("%#x", uint(bit))
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ",")
+}
+
+// RuntimeInfo describes the Objective-C runtime the package is linked with.
+type RuntimeInfo struct {
+	Flavor Flavor
+	// ABI is the version of the runtime ABI: 2 for the modern Apple runtime
+	// and the GNUstep 2.0 ABI, and 1 for older ABIs.
+	ABI          int
+	Capabilities Capability
+}
+
+// Has checks if the runtime has all given capabilities.
+func (r RuntimeInfo) Has(c Capability) bool {
+	return r.Capabilities.Has(c)
+}
+
+var runtimeOnce struct {
+	sync.Once
+	info RuntimeInfo
+}
+
+// Runtime reports which Objective-C runtime is used and which optional features it supports.
+//
+// On Linux, the runtime is detected by symbols it exports, since both GNUstep and GCC
+// runtimes are linked as libobjc.
+func Runtime() RuntimeInfo {
+	runtimeOnce.Do(func() {
+		runtimeOnce.info = runtimeInfo()
+	})
+	return runtimeOnce.info
+}
@@ -0,0 +1,70 @@
+package objc
+
+// RegisterSelector registers a method name with the runtime and returns the selector.
+//
+// See sel_registerName.
+func RegisterSelector(name string) Selector {
+	return Selector{sel: sel_registerName(name)}
+}
+
+// RegisterTypedSelector registers a method name with a given type encoding.
+//
+// Typed selectors are only supported by GNU runtimes. On other runtimes
+// it returns an untyped selector, same as RegisterSelector.
+//
+// See sel_registerTypedName_np.
+func RegisterTypedSelector(name, types string) Selector {
+	if s, ok := sel_registerTypedName(name, types); ok {
+		return Selector{sel: s}
+	}
+	return Selector{sel: sel_registerName(name)}
+}
+
+// Selector is a registered method name.
+type Selector struct {
+	sel cSEL
+}
+
+// IsNil checks if the selector is nil.
+func (s Selector) IsNil() bool {
+	return s.sel == nil
+}
+
+func (s Selector) String() string {
+	if s.IsNil() {
+		return "<nil>"
+	}
+	return s.Name()
+}
+
+// Name returns the name of the method specified by a given selector.
+//
+// See sel_getName.
+func (s Selector) Name() string {
+	if s.IsNil() {
+		return ""
+	}
+	return sel_getName(s.sel)
+}
+
+// TypeEncoding returns the type encoding of a typed selector.
+// It returns an empty string for untyped selectors or if the runtime doesn't support them.
+//
+// See sel_getType_np.
+func (s Selector) TypeEncoding() string {
+	if s.IsNil() {
+		return ""
+	}
+	return sel_getType(s.sel)
+}
+
+// Equal checks if two selectors are the same.
+// Typed selectors with the same name but different types are considered equal.
+//
+// See sel_isEqual.
+func (s Selector) Equal(s2 Selector) bool {
+	if s.IsNil() || s2.IsNil() {
+		return s.IsNil() == s2.IsNil()
+	}
+	return sel_isEqual(s.sel, s2.sel)
+}
@@ -0,0 +1,209 @@
+package objc
+
+import (
+	"fmt"
+	"math"
+	"unsafe"
+
+	"github.com/dennwc/go-apple/objc/encoding"
+)
+
+// Object returns the class as an object that can receive messages.
+func (c *Class) Object() Object {
+	if !c.Valid() {
+		return Object{}
+	}
+	return Object{id: cID(unsafe.Pointer(c.class))}
+}
+
+// Send sends a message to the class. See Object.Send for details.
+func (c *Class) Send(sel Selector, args ...interface{}) (interface{}, error) {
+	return c.Object().Send(sel, args...)
+}
+
+// Send sends a message with arguments to the object and returns the result.
+//
+// Arguments are converted according to the type encoding of the method, and the
+// result is returned as described in Object.GetIvar. Methods returning void return nil.
+// Pointer arguments must not point to Go memory.
+//
+// Only arguments passed in registers are supported: up to 4 integer, pointer or object
+// arguments and up to 8 floating point arguments. Structures, unions, arrays and
+// variadic arguments are not supported.
+//
+// If the receiver doesn't implement the method, the type encoding of a typed selector is used.
+// Otherwise, the signature returned by methodSignatureForSelector: is used, which allows
+// sending messages that the receiver forwards, see ClassBuilder.SetForwardInvocation.
+// Sending a message to a nil object returns nil.
+// Objective-C exceptions raised by the method are returned as *Exception, if the runtime reports CapExceptions.
+func (o Object) Send(sel Selector, args ...interface{}) (interface{}, error) {
+	return o.send(nil, sel, args)
+}
+
+// send sends a message to the object using a given implementation.
+// If imp is nil, the implementation is looked up by the runtime.
+func (o Object) send(imp unsafe.Pointer, sel Selector, args []interface{}) (interface{}, error) {
+	if sel.IsNil() {
+		return nil, fmt.Errorf("objc: send nil selector")
+	} else if o.IsNil() {
+		return nil, nil
+	}
+	types := o.methodTypes(sel)
+	if types == "" {
+		return nil, fmt.Errorf("objc: %s does not respond to %q", o.Class(), sel.Name())
+	}
+	sig, err := encoding.ParseMethod(types)
+	if err != nil {
+		return nil, fmt.Errorf("objc: %q: %v", sel.Name(), err)
+	}
+	if len(sig.Args) < 2 {
+		return nil, fmt.Errorf("objc: %q: invalid method type encoding: %q", sel.Name(), types)
+	}
+	// skip self and _cmd
+	targs := sig.Args[2:]
+	if len(args) != len(targs) {
+		return nil, fmt.Errorf("objc: %q: expected %d arguments, got %d", sel.Name(), len(targs), len(args))
+	}
+	var a callArgs
+	for i, arg := range targs {
+		if err := a.add(arg.Type.String(), args[i]); err != nil {
+			return nil, fmt.Errorf("objc: %q: argument %d: %v", sel.Name(), i, err)
+		}
+	}
+	if imp == nil {
+		imp = msgLookup(o.id, sel.sel)
+	}
+	if imp == nil {
+		return nil, fmt.Errorf("objc: %s does not respond to %q", o.Class(), sel.Name())
+	}
+	return a.call(imp, o.Pointer(), unsafe.Pointer(sel.sel), sig.Return.String())
+}
+
+// methodTypes returns the type encoding of a method the object responds to.
+// It returns an empty string if the type encoding is unknown.
+func (o Object) methodTypes(sel Selector) string {
+	if m := class_getInstanceMethod(object_getClass(o.id), sel.sel); m != nil {
+		return Method{method: m}.TypeEncoding()
+	}
+	if types := sel.TypeEncoding(); types != "" {
+		return types
+	}
+	return o.forwardedTypes(sel)
+}
+
+const (
+	// maxIntArgs is the number of integer and pointer arguments that can be passed
+	// in registers, excluding the receiver and the selector.
+	maxIntArgs = 4
+	// maxFloatArgs is the number of floating point arguments that can be passed in registers.
+	maxFloatArgs = 8
+)
+
+// callArgs holds register values of method arguments.
+type callArgs struct {
+	ints   [maxIntArgs]uint64
+	floats [maxFloatArgs]float64
+	ni, nf int
+}
+
+// add converts a Go value to a register value according to the type encoding.
+func (a *callArgs) add(enc string, val interface{}) error {
+	t, err := valueType(enc)
+	if err != nil {
+		return err
+	}
+	var w uint64
+	switch {
+	case t == encoding.Float || t == encoding.Double:
+		if a.nf >= maxFloatArgs {
+			return fmt.Errorf("too many floating point arguments")
+		}
+		if err := writeType(unsafe.Pointer(&w), t, val); err != nil {
+			return err
+		}
+		a.floats[a.nf] = math.Float64frombits(w)
+		a.nf++
+		return nil
+	case isInteger(t) && t.(encoding.Basic).IsUnsigned():
+		v, err := uintValue(val, t.(encoding.Basic))
+		if err != nil {
+			return err
+		}
+		w = v
+	case isInteger(t):
+		// registers must be sign-extended
+		v, err := intValue(val, t.(encoding.Basic))
+		if err != nil {
+			return err
+		}
+		w = uint64(v)
+	case t == encoding.Bool || isPointer(t):
+		switch t.(type) {
+		case encoding.Object, encoding.Block:
+			val = objectValue(val)
+		}
+		if err := writeType(unsafe.Pointer(&w), t, val); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported type encoding: %q", enc)
+	}
+	if a.ni >= maxIntArgs {
+		return fmt.Errorf("too many integer arguments")
+	}
+	a.ints[a.ni] = w
+	a.ni++
+	return nil
+}
+
+// isInteger checks if the type is an integer that fits into a register.
+func isInteger(t encoding.Type) bool {
+	b, ok := t.(encoding.Basic)
+	return ok && b.IsInteger() && b.Size() <= 8
+}
+
+// objectValue converts classes, blocks and invocations to objects. Other values are returned as is.
+func objectValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case *Class:
+		return v.Object()
+	case Block:
+		return v.Object
+	case Invocation:
+		return v.Object
+	}
+	return val
+}
+
+// call calls the function with a given return type encoding and converts the result to a Go value.
+func (a *callArgs) call(imp, self, sel unsafe.Pointer, ret string) (interface{}, error) {
+	t, err := valueType(ret)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case t == encoding.Void:
+		_, err := callInt(imp, self, sel, a)
+		return nil, err
+	case t == encoding.Float:
+		v, err := callFloat(imp, self, sel, a)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	case t == encoding.Double:
+		v, err := callDouble(imp, self, sel, a)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	case isInteger(t) || t == encoding.Bool || isPointer(t):
+		// register values are little-endian, thus smaller types can be read from the start
+		w, err := callInt(imp, self, sel, a)
+		if err != nil {
+			return nil, err
+		}
+		return readType(unsafe.Pointer(&w), t)
+	}
+	return nil, fmt.Errorf("unsupported return type encoding: %q", ret)
+}
@@ -0,0 +1,122 @@
+package objc
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/dennwc/go-apple/objc/encoding"
+)
+
+// SendIMP sends a message to the object using a given implementation instead of looking it up.
+// It is useful for calling the original implementation of a replaced method.
+//
+// The type encoding of the method is taken from the receiver, see Send for details.
+func (o Object) SendIMP(imp IMP, sel Selector, args ...interface{}) (interface{}, error) {
+	if imp == nil {
+		return nil, fmt.Errorf("objc: send with nil implementation")
+	}
+	return o.send(unsafe.Pointer(imp), sel, args)
+}
+
+// ReplaceMethod replaces the implementation of an instance method with a Go function
+// and returns the original implementation. The method may be inherited from a superclass,
+// in which case it is overridden in this class only.
+//
+// The function must accept the same arguments as for ClassBuilder.AddMethod and its
+// signature must be compatible with the type encoding of the method. The original
+// implementation can be called from the function with Object.SendIMP.
+//
+// Methods already implemented in Go for the class or its superclasses cannot be replaced.
+//
+// See class_replaceMethod.
+func (c *Class) ReplaceMethod(sel Selector, fnc interface{}) (IMP, error) {
+	if !c.Valid() {
+		return nil, fmt.Errorf("objc: replace method of nil class")
+	}
+	return replaceMethod(c.class, sel, fnc)
+}
+
+// ReplaceClassMethod replaces the implementation of a class method with a Go function.
+// See ReplaceMethod for details.
+func (c *Class) ReplaceClassMethod(sel Selector, fnc interface{}) (IMP, error) {
+	if !c.Valid() {
+		return nil, fmt.Errorf("objc: replace method of nil class")
+	}
+	return replaceMethod(object_getClass(c.Object().id), sel, fnc)
+}
+
+func replaceMethod(c cClass, sel Selector, fnc interface{}) (IMP, error) {
+	if sel.IsNil() {
+		return nil, fmt.Errorf("objc: replace method with nil selector")
+	}
+	name := sel.Name()
+	cm := class_getInstanceMethod(c, sel.sel)
+	if cm == nil {
+		return nil, fmt.Errorf("objc: %s does not implement %q", class_getName(c), name)
+	} else if findGoMethod(c, name) != nil {
+		return nil, fmt.Errorf("objc: %q is already implemented in Go", name)
+	}
+	m, err := newGoMethod(fnc)
+	if err != nil {
+		return nil, fmt.Errorf("objc: %q: %v", name, err)
+	}
+	// keep the original type encoding, it may contain more details
+	types := Method{method: cm}.TypeEncoding()
+	if err = m.checkTypes(types); err != nil {
+		return nil, fmt.Errorf("objc: %q: %v", name, err)
+	}
+	imp, err := m.imp(c)
+	if err != nil {
+		return nil, fmt.Errorf("objc: %q: %v", name, err)
+	}
+	orig := class_getMethodImplementation(c, sel.sel)
+	setGoMethod(c, name, m)
+	class_replaceMethod(c, sel.sel, imp, types)
+	return IMP(orig), nil
+}
+
+// checkTypes checks that the Go method is compatible with a given method type encoding.
+func (m *goMethod) checkTypes(types string) error {
+	sig, err := encoding.ParseMethod(types)
+	if err != nil {
+		return err
+	}
+	if len(sig.Args) != len(m.args)+2 {
+		return fmt.Errorf("expected %d arguments, got %d", len(sig.Args)-2, len(m.args))
+	}
+	if !compatibleType(sig.Return, m.ret) {
+		return fmt.Errorf("incompatible result type: %q vs %q", sig.Return, m.ret)
+	}
+	for i, a := range sig.Args[2:] {
+		if !compatibleType(a.Type, m.args[i]) {
+			return fmt.Errorf("incompatible argument %d type: %q vs %q", i, a.Type, m.args[i])
+		}
+	}
+	return nil
+}
+
+// compatibleType checks if values of a given type are passed the same way as values of the Go type encoding.
+func compatibleType(t encoding.Type, enc string) bool {
+	t = encoding.Unqualified(t)
+	if t.String() == enc {
+		return true
+	}
+	gt, err := encoding.Parse(enc)
+	if err != nil {
+		return false
+	}
+	isFloat := func(t encoding.Type) bool {
+		b, ok := t.(encoding.Basic)
+		return ok && b.IsFloat()
+	}
+	switch t := t.(type) {
+	case encoding.Basic:
+		if t == encoding.Void || t.Size() == 0 || t.Size() > 8 {
+			return false
+		}
+	case encoding.Object, encoding.Block, encoding.Pointer:
+	default:
+		return false
+	}
+	return isFloat(t) == isFloat(gt) && t.Size() == gt.Size()
+}
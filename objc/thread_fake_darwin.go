@@ -0,0 +1,17 @@
+//go:build objcfake
+// +build objcfake
+
+package objc
+
+import "syscall"
+
+// fakeThreads is set if threadID can tell OS threads apart.
+const fakeThreads = true
+
+// threadID returns an identifier of the current OS thread.
+//
+// See thread_selfid.
+func threadID() uintptr {
+	id, _, _ := syscall.RawSyscall(syscall.SYS_THREAD_SELFID, 0, 0, 0)
+	return id
+}
@@ -0,0 +1,14 @@
+//go:build objcfake
+// +build objcfake
+
+package objc
+
+import "syscall"
+
+// fakeThreads is set if threadID can tell OS threads apart.
+const fakeThreads = true
+
+// threadID returns an identifier of the current OS thread.
+func threadID() uintptr {
+	return uintptr(syscall.Gettid())
+}
@@ -0,0 +1,16 @@
+//go:build objcfake && !linux && !darwin
+// +build objcfake,!linux,!darwin
+
+package objc
+
+// fakeThreads is set if threadID can tell OS threads apart.
+//
+// Identifiers of OS threads are not available without cgo on this system,
+// thus the fake runtime doesn't support autorelease pools here, and all threads
+// are reported as the main one.
+const fakeThreads = false
+
+// threadID returns an identifier of the current OS thread.
+func threadID() uintptr {
+	return 1
+}
@@ -0,0 +1,132 @@
+//go:build !objcfake
+// +build !objcfake
+
+#include <stddef.h>
+#include <stdint.h>
+#include <string.h>
+#include "_cgo_export.h"
+
+// Trampolines that are used as IMPs of methods implemented in Go.
+// They receive all argument registers and pass them to goObjcImp that decodes them
+// according to the method type encoding. See also go_call_int in call.h.
+//
+// There is a set of trampolines for each depth of a class in the hierarchy. The depth
+// identifies the class the method was added to, since it's always an ancestor of the receiver
+// class. This way, methods called with objc_msgSendSuper reach the implementation of the superclass.
+
+#define GO_IMP_DEPTHS 32
+
+static uint64_t go_imp_invoke(int depth, void *self, void *cmd,
+	uintptr_t a0, uintptr_t a1, uintptr_t a2, uintptr_t a3,
+	double f0, double f1, double f2, double f3, double f4, double f5, double f6, double f7) {
+	uintptr_t a[4] = {a0, a1, a2, a3};
+	double f[8] = {f0, f1, f2, f3, f4, f5, f6, f7};
+	uint64_t out = 0;
+	goObjcImp(depth, self, cmd, a, f, &out);
+	return out;
+}
+
+#define GO_IMP_ARGS void *self, void *cmd, \
+	uintptr_t a0, uintptr_t a1, uintptr_t a2, uintptr_t a3, \
+	double f0, double f1, double f2, double f3, double f4, double f5, double f6, double f7
+
+#define GO_IMP_INVOKE(depth) \
+	go_imp_invoke(depth, self, cmd, a0, a1, a2, a3, f0, f1, f2, f3, f4, f5, f6, f7)
+
+#define GO_IMP(depth) \
+	static uintptr_t go_imp_int_##depth(GO_IMP_ARGS) { \
+		return (uintptr_t)GO_IMP_INVOKE(depth); \
+	} \
+	static float go_imp_float_##depth(GO_IMP_ARGS) { \
+		uint64_t out = GO_IMP_INVOKE(depth); \
+		float v; \
+		memcpy(&v, &out, sizeof(v)); \
+		return v; \
+	} \
+	static double go_imp_double_##depth(GO_IMP_ARGS) { \
+		uint64_t out = GO_IMP_INVOKE(depth); \
+		double v; \
+		memcpy(&v, &out, sizeof(v)); \
+		return v; \
+	}
+
+GO_IMP(0)
+GO_IMP(1)
+GO_IMP(2)
+GO_IMP(3)
+GO_IMP(4)
+GO_IMP(5)
+GO_IMP(6)
+GO_IMP(7)
+GO_IMP(8)
+GO_IMP(9)
+GO_IMP(10)
+GO_IMP(11)
+GO_IMP(12)
+GO_IMP(13)
+GO_IMP(14)
+GO_IMP(15)
+GO_IMP(16)
+GO_IMP(17)
+GO_IMP(18)
+GO_IMP(19)
+GO_IMP(20)
+GO_IMP(21)
+GO_IMP(22)
+GO_IMP(23)
+GO_IMP(24)
+GO_IMP(25)
+GO_IMP(26)
+GO_IMP(27)
+GO_IMP(28)
+GO_IMP(29)
+GO_IMP(30)
+GO_IMP(31)
+
+#define GO_IMP_ENTRY(depth) \
+	{(void*)go_imp_int_##depth, (void*)go_imp_float_##depth, (void*)go_imp_double_##depth}
+
+static void *go_imp_table[GO_IMP_DEPTHS][3] = {
+	GO_IMP_ENTRY(0),
+	GO_IMP_ENTRY(1),
+	GO_IMP_ENTRY(2),
+	GO_IMP_ENTRY(3),
+	GO_IMP_ENTRY(4),
+	GO_IMP_ENTRY(5),
+	GO_IMP_ENTRY(6),
+	GO_IMP_ENTRY(7),
+	GO_IMP_ENTRY(8),
+	GO_IMP_ENTRY(9),
+	GO_IMP_ENTRY(10),
+	GO_IMP_ENTRY(11),
+	GO_IMP_ENTRY(12),
+	GO_IMP_ENTRY(13),
+	GO_IMP_ENTRY(14),
+	GO_IMP_ENTRY(15),
+	GO_IMP_ENTRY(16),
+	GO_IMP_ENTRY(17),
+	GO_IMP_ENTRY(18),
+	GO_IMP_ENTRY(19),
+	GO_IMP_ENTRY(20),
+	GO_IMP_ENTRY(21),
+	GO_IMP_ENTRY(22),
+	GO_IMP_ENTRY(23),
+	GO_IMP_ENTRY(24),
+	GO_IMP_ENTRY(25),
+	GO_IMP_ENTRY(26),
+	GO_IMP_ENTRY(27),
+	GO_IMP_ENTRY(28),
+	GO_IMP_ENTRY(29),
+	GO_IMP_ENTRY(30),
+	GO_IMP_ENTRY(31),
+};
+
+// go_imp_trampoline returns a trampoline for a given class depth and result kind:
+// 0 for integers and pointers, 1 for float and 2 for double.
+// It returns NULL if the class is too deep in the hierarchy.
+void *go_imp_trampoline(int depth, int kind) {
+	if (depth < 0 || depth >= GO_IMP_DEPTHS || kind < 0 || kind > 2) {
+		return NULL;
+	}
+	return go_imp_table[depth][kind];
+}
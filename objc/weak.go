@@ -0,0 +1,84 @@
+package objc
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// Weak is a weak reference to an object. It doesn't keep the object alive
+// and becomes nil when the object is deallocated.
+//
+// The reference is destroyed when Close is called or when the Weak is garbage collected.
+type Weak struct {
+	mu  sync.Mutex
+	loc *cID // allocated in C memory, since the runtime updates it
+}
+
+// NewWeak creates a weak reference to the object.
+// It returns an error if the runtime does not support weak references.
+//
+// See objc_initWeak.
+func NewWeak(o Object) (*Weak, error) {
+	var id cID
+	loc := (*cID)(malloc(unsafe.Sizeof(id)))
+	*loc = nil
+	if !objc_initWeak(loc, o.id) {
+		free(unsafe.Pointer(loc))
+		return nil, fmt.Errorf("objc: weak references are not supported by the runtime")
+	}
+	w := &Weak{loc: loc}
+	runtime.SetFinalizer(w, (*Weak).Close)
+	return w, nil
+}
+
+// Load returns an owning reference to the object.
+// The object of the returned reference is nil if the object was deallocated or the Weak was closed.
+//
+// See objc_loadWeakRetained.
+func (w *Weak) Load() *Ref {
+	if w == nil {
+		return Adopt(Object{})
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.loc == nil {
+		return Adopt(Object{})
+	}
+	return Adopt(Object{id: objc_loadWeakRetained(w.loc)})
+}
+
+// Store replaces the object the reference points to.
+// It does nothing if the Weak was closed.
+//
+// See objc_storeWeak.
+func (w *Weak) Store(o Object) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.loc != nil {
+		objc_storeWeak(w.loc, o.id)
+	}
+}
+
+// Close destroys the weak reference. It is safe to call Close multiple times.
+//
+// See objc_destroyWeak.
+func (w *Weak) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.loc == nil {
+		return nil
+	}
+	objc_destroyWeak(w.loc)
+	free(unsafe.Pointer(w.loc))
+	w.loc = nil
+	runtime.SetFinalizer(w, nil)
+	return nil
+}